@@ -139,6 +139,19 @@ func TestProbabilisticFilter(t *testing.T) {
 	cfg.ProbabilisticFilteringRatio = &ratio
 }
 
+func TestPolicyStats(t *testing.T) {
+	cascading := createCascadingEvaluator(t)
+
+	// Long trace is selected by "duration" policy, the short one is not
+	cascading.makeProvisionalDecision(pdata.NewTraceID([16]byte{0}), createTrace(cascading, 8, 1000000))
+	cascading.makeProvisionalDecision(pdata.NewTraceID([16]byte{1}), createTrace(cascading, 8, 1000))
+
+	stats := cascading.PolicyStats()
+	require.Len(t, stats, 2)
+	require.Equal(t, PolicyStat{Evaluated: 2, Sampled: 1, NotSampled: 1}, stats["duration"])
+	require.Equal(t, PolicyStat{Evaluated: 2, SecondChance: 2}, stats["everything else"])
+}
+
 //func TestSecondChanceReevaluation(t *testing.T) {
 //	cascading := createCascadingEvaluator()
 //
@@ -28,10 +28,87 @@ type PolicyCfg struct {
 	NumericAttributeCfg *NumericAttributeCfg `mapstructure:"numeric_attribute"`
 	// Configs for string attribute filter sampling policy evaluator.
 	StringAttributeCfg *StringAttributeCfg `mapstructure:"string_attribute"`
+	// Configs for boolean attribute filter sampling policy evaluator.
+	BooleanAttributeCfg *BooleanAttributeCfg `mapstructure:"boolean_attribute"`
+	// Configs for resource attribute filter sampling policy evaluator.
+	ResourceAttributeCfg *ResourceAttributeCfg `mapstructure:"resource_attribute"`
+	// Configs for cross field match sampling policy evaluator.
+	CrossFieldMatchCfg *CrossFieldMatchCfg `mapstructure:"cross_field_match"`
+	// Configs for numeric comparison sampling policy evaluator.
+	NumericComparisonCfg *NumericComparisonCfg `mapstructure:"numeric_comparison"`
+	// Configs for distinct attribute values sampling policy evaluator.
+	MinDistinctAttributeValuesCfg *MinDistinctAttributeValuesCfg `mapstructure:"min_distinct_attribute_values"`
+	// Configs for span error sampling policy evaluator.
+	ErrorCfg *ErrorCfg `mapstructure:"error"`
+	// Configs for CIDR match sampling policy evaluator.
+	CIDRMatchCfg *CIDRMatchCfg `mapstructure:"cidr_match"`
+	// Configs for status code sampling policy evaluator.
+	StatusCodeCfg *StatusCodeCfg `mapstructure:"status_code"`
+	// Configs for new deployment sampling policy evaluator.
+	NewDeploymentCfg *NewDeploymentCfg `mapstructure:"new_deployment"`
 	// Configs for properties sampling policy evaluator.
 	PropertiesCfg PropertiesCfg `mapstructure:"properties"`
+	// SubPolicies (optional) are the criteria combined according to Operator, the result must be met along with
+	// the other criteria of this policy.
+	SubPolicies []SubPolicyCfg `mapstructure:"sub_policies"`
+	// Operator describes how the SubPolicies are combined, either "and" or "or". Default: "and"
+	Operator string `mapstructure:"operator"`
 	// SpansPerSecond specifies the rule budget that should never be exceeded for it
 	SpansPerSecond int64 `mapstructure:"spans_per_second"`
+	// SecondChanceOnRateLimit (optional) makes the policy give "SecondChance" to the matching traces exceeding its
+	// SpansPerSecond, rather than leaving them to other policies. Default: false
+	SecondChanceOnRateLimit bool `mapstructure:"second_chance_on_rate_limit"`
+	// ReservedBudgetRatio (optional) describes which part (0.0-1.0) of the global SpansPerSecond budget is reserved
+	// for traces selected by this policy, so other policies cannot use it up.
+	ReservedBudgetRatio float64 `mapstructure:"reserved_budget_ratio"`
+	// Priority (optional) makes the "SecondChance" traces of this policy favored over the ones of policies with
+	// lower priority when they compete for what is left of the global limit. Default: 0
+	Priority int `mapstructure:"priority"`
+	// InvertMatch specifies if the match should be inverted. Default: false
+	InvertMatch bool `mapstructure:"invert_match"`
+	// Drop makes the policy reject the matching traces, even if they are selected by other policies. Default: false
+	Drop bool `mapstructure:"drop"`
+}
+
+// SubPolicyCfg holds the criteria of a sub-policy. It might combine sub-policies of its own, which cannot be nested
+// any further (the config types must not be recursive).
+type SubPolicyCfg struct {
+	PolicyCriteriaCfg `mapstructure:",squash"`
+	// SubPolicies (optional) are the criteria combined according to Operator, the result must be met along with
+	// the other criteria of this sub-policy.
+	SubPolicies []PolicyCriteriaCfg `mapstructure:"sub_policies"`
+	// Operator describes how the SubPolicies are combined, either "and" or "or". Default: "and"
+	Operator string `mapstructure:"operator"`
+}
+
+// PolicyCriteriaCfg holds the criteria of the innermost sub-policy, which are the same as the ones of PolicyCfg.
+type PolicyCriteriaCfg struct {
+	// Name given to the sub-policy to make easy to identify it.
+	Name string `mapstructure:"name"`
+	// Configs for numeric attribute filter.
+	NumericAttributeCfg *NumericAttributeCfg `mapstructure:"numeric_attribute"`
+	// Configs for string attribute filter.
+	StringAttributeCfg *StringAttributeCfg `mapstructure:"string_attribute"`
+	// Configs for boolean attribute filter.
+	BooleanAttributeCfg *BooleanAttributeCfg `mapstructure:"boolean_attribute"`
+	// Configs for resource attribute filter.
+	ResourceAttributeCfg *ResourceAttributeCfg `mapstructure:"resource_attribute"`
+	// Configs for cross field match filter.
+	CrossFieldMatchCfg *CrossFieldMatchCfg `mapstructure:"cross_field_match"`
+	// Configs for numeric comparison filter.
+	NumericComparisonCfg *NumericComparisonCfg `mapstructure:"numeric_comparison"`
+	// Configs for distinct attribute values filter.
+	MinDistinctAttributeValuesCfg *MinDistinctAttributeValuesCfg `mapstructure:"min_distinct_attribute_values"`
+	// Configs for span error filter.
+	ErrorCfg *ErrorCfg `mapstructure:"error"`
+	// Configs for CIDR match filter.
+	CIDRMatchCfg *CIDRMatchCfg `mapstructure:"cidr_match"`
+	// Configs for status code filter.
+	StatusCodeCfg *StatusCodeCfg `mapstructure:"status_code"`
+	// Configs for new deployment filter.
+	NewDeploymentCfg *NewDeploymentCfg `mapstructure:"new_deployment"`
+	// Configs for properties filter.
+	PropertiesCfg PropertiesCfg `mapstructure:"properties"`
 	// InvertMatch specifies if the match should be inverted. Default: false
 	InvertMatch bool `mapstructure:"invert_match"`
 }
@@ -44,6 +121,27 @@ type PropertiesCfg struct {
 	MinDuration *time.Duration `mapstructure:"min_duration"`
 	// MinNumberOfSpans (optional) is the minimum number spans that must be present in a matching trace.
 	MinNumberOfSpans *int `mapstructure:"min_number_of_spans"`
+	// MinEstimatedSize (optional) is the minimum estimated size of a matching trace in bytes. It's an approximation of
+	// the serialized size, calculated as the total length of span names and of attribute (span and resource) keys
+	// and values.
+	MinEstimatedSize *int64 `mapstructure:"min_estimated_size"`
+	// MaxEstimatedSize (optional) is the maximum estimated size of a matching trace in bytes, see MinEstimatedSize.
+	MaxEstimatedSize *int64 `mapstructure:"max_estimated_size"`
+	// MinErrorSpanRatio (optional) is the minimum fraction (0.0-1.0) of spans with error status in a matching trace.
+	MinErrorSpanRatio *float64 `mapstructure:"min_error_span_ratio"`
+	// MinNumberOfErrors (optional) is the minimum number of spans with error status in a matching trace.
+	MinNumberOfErrors *int `mapstructure:"min_number_of_errors"`
+	// RemoteParent (optional) when set to true selects traces initiated externally, i.e. without a root span and
+	// with a server or consumer span referring to a parent which is not a part of the trace. When set to false,
+	// selects traces initiated internally, i.e. containing the root span.
+	RemoteParent *bool `mapstructure:"remote_parent"`
+	// SpanKinds (optional) is the list of span kinds ("SERVER", "CLIENT", "PRODUCER", "CONSUMER", "INTERNAL" or
+	// "UNSPECIFIED"), at least one of which must be present in a matching trace. Empty list matches any trace.
+	SpanKinds []string `mapstructure:"span_kinds"`
+	// MinNetworkLatency (optional) is the minimum gap between the duration of a client span and the duration of
+	// the server span it called (i.e. the server span which parent is the client span), present in a matching trace.
+	// The gap approximates the time spent in the network.
+	MinNetworkLatency *time.Duration `mapstructure:"min_network_latency"`
 }
 
 // NumericAttributeCfg holds the configurable settings to create a numeric attribute filter
@@ -55,6 +153,10 @@ type NumericAttributeCfg struct {
 	MinValue int64 `mapstructure:"min_value"`
 	// MaxValue is the maximum value of the attribute to be considered a match.
 	MaxValue int64 `mapstructure:"max_value"`
+	// MinDoubleValue and MaxDoubleValue (optional) replace MinValue and MaxValue with fractional bounds. They must be
+	// set together, both int and double attributes are then compared against them.
+	MinDoubleValue *float64 `mapstructure:"min_double_value"`
+	MaxDoubleValue *float64 `mapstructure:"max_double_value"`
 }
 
 // StringAttributeCfg holds the configurable settings to create a string attribute filter
@@ -64,6 +166,131 @@ type StringAttributeCfg struct {
 	Key string `mapstructure:"key"`
 	// Values is the set of attribute values that if any is equal to the actual attribute value to be considered a match.
 	Values []string `mapstructure:"values"`
+	// ValuePatterns (optional) is the list of regular expressions, the attribute value matching any of them
+	// is considered a match as well.
+	ValuePatterns []string `mapstructure:"value_patterns"`
+	// InvertMatch (optional) when set to true makes the trace a match only if none of its spans (and resources)
+	// has any of the Values. Unlike the policy level InvertMatch, only this condition is inverted.
+	InvertMatch bool `mapstructure:"invert_match"`
+}
+
+// BooleanAttributeCfg holds the configurable settings to create a boolean attribute filter
+// sampling policy evaluator.
+type BooleanAttributeCfg struct {
+	// Tag that the filter is going to be matching against.
+	Key string `mapstructure:"key"`
+	// Value is the attribute value to be considered a match. Attributes of other types than boolean never match.
+	Value bool `mapstructure:"value"`
+}
+
+// ResourceAttributeCfg holds the configurable settings to create a filter matching traces which have a resource
+// with the string attribute equal to any of the values. Unlike StringAttributeCfg, span attributes are not considered.
+type ResourceAttributeCfg struct {
+	// Key is the resource attribute the filter is going to be matching against, e.g. "service.name".
+	Key string `mapstructure:"key"`
+	// Values is the set of attribute values that if any is equal to the actual attribute value to be considered a match.
+	Values []string `mapstructure:"values"`
+}
+
+// CrossFieldMatchCfg holds the configurable settings to create a filter matching traces which have
+// a resource attribute equal to an attribute of one of its spans
+type CrossFieldMatchCfg struct {
+	// ResourceKey is the resource attribute compared with the span attribute.
+	ResourceKey string `mapstructure:"resource_key"`
+	// SpanKey is the span attribute compared with the resource attribute.
+	SpanKey string `mapstructure:"span_key"`
+}
+
+// NumericComparisonCfg holds the configurable settings to create a filter matching traces which have a span
+// with two numeric attributes satisfying the comparison, e.g. FirstKey "retry_count" being greater ("gt") than
+// SecondKey "max_retries"
+type NumericComparisonCfg struct {
+	// FirstKey is the span attribute on the left side of the comparison.
+	FirstKey string `mapstructure:"first_key"`
+	// SecondKey is the span attribute on the right side of the comparison.
+	SecondKey string `mapstructure:"second_key"`
+	// Operator is one of "gt", "lt" or "eq".
+	Operator string `mapstructure:"operator"`
+}
+
+// MinDistinctAttributeValuesCfg holds the configurable settings to create a filter matching traces which spans
+// have at least MinValues distinct values of the attribute
+type MinDistinctAttributeValuesCfg struct {
+	// Key is the span (or resource) attribute which distinct values are counted.
+	Key string `mapstructure:"key"`
+	// MinValues is the minimum number of distinct values of the attribute to be considered a match.
+	MinValues int `mapstructure:"min_values"`
+}
+
+// ErrorCfg holds the configurable settings to create a filter matching traces which have a span with error status
+type ErrorCfg struct {
+	// ChildErrorOnly when set to true requires the span with error status to have a parent, so errors
+	// of the root span alone are not considered a match.
+	ChildErrorOnly bool `mapstructure:"child_error_only"`
+}
+
+// StatusCodeCfg holds the configurable settings to create a filter matching traces which have a span with
+// one of the given status codes
+type StatusCodeCfg struct {
+	// StatusCodes is the list of matched status codes, each of "OK", "ERROR" or "UNSET".
+	StatusCodes []string `mapstructure:"status_codes"`
+}
+
+// CIDRMatchCfg holds the configurable settings to create a filter matching traces which have an attribute
+// with an IP address within one of the given ranges
+type CIDRMatchCfg struct {
+	// Key is the attribute holding the IP address, e.g. "net.host.ip".
+	Key string `mapstructure:"key"`
+	// CIDRs is the list of ranges in CIDR notation, e.g. "10.0.0.0/8".
+	CIDRs []string `mapstructure:"cidrs"`
+}
+
+// NewDeploymentCfg holds the configurable settings to create a filter matching traces of deployments which were
+// seen for the first time recently, so their baseline can be captured
+type NewDeploymentCfg struct {
+	// Key is the resource attribute identifying the deployment, e.g. "service.version".
+	Key string `mapstructure:"key"`
+	// MaxTraces (optional) is the number of the first traces of a deployment to be considered a match.
+	MaxTraces int64 `mapstructure:"max_traces"`
+	// Duration (optional) is the time since the first sighting of a deployment during which its traces are
+	// considered a match. When set along with MaxTraces, reaching either of them ends the matching.
+	Duration time.Duration `mapstructure:"duration"`
+	// MaxDeployments (optional) limits the number of tracked deployments, the earliest seen are forgotten first
+	// (and considered new again if they are seen later). Default: 1000
+	MaxDeployments int `mapstructure:"max_deployments"`
+}
+
+// DecisionLogCfg holds the configurable settings of the decision log, which records decisions made for the traces,
+// so they can be analyzed (or the traffic replayed against new policies) offline.
+type DecisionLogCfg struct {
+	// Path of the file the decision records are appended to, one JSON object per line.
+	Path string `mapstructure:"path"`
+	// SamplingRatio (0.0-1.0] describes which part of the decisions is recorded.
+	SamplingRatio float64 `mapstructure:"sampling_ratio"`
+}
+
+// DroppedSampleCfg holds the configurable settings of the dropped sample, which forwards a part of the traces
+// that were not sampled to a separate exporter, so what the filter discards can be audited.
+type DroppedSampleCfg struct {
+	// Exporter is the name of the traces exporter receiving the dropped sample.
+	Exporter string `mapstructure:"exporter"`
+	// SamplingRatio (0.0-1.0] describes which part of the dropped traces is forwarded.
+	SamplingRatio float64 `mapstructure:"sampling_ratio"`
+}
+
+// PreFilterCfg holds the configurable settings of the pre-filter, which drops the traces having spans of resources
+// matching the deny list (or not matching the allow list) before they are buffered and evaluated by the policies.
+// The first resource of a trace which is matched decides for the whole trace.
+type PreFilterCfg struct {
+	// ForceKeep (optional) is the list of resource attribute matchers, the traces having spans of resources matching
+	// any of them are sampled right away, regardless of the policies and limits. It takes precedence over Deny.
+	ForceKeep []ResourceAttributeCfg `mapstructure:"force_keep"`
+	// Allow (optional) is the list of resource attribute matchers, at least one of which must be matched for
+	// the spans to be kept. Empty list allows any resource.
+	Allow []ResourceAttributeCfg `mapstructure:"allow"`
+	// Deny (optional) is the list of resource attribute matchers, the spans of resources matching any of them
+	// are dropped. It takes precedence over Allow.
+	Deny []ResourceAttributeCfg `mapstructure:"deny"`
 }
 
 // Config holds the configuration for cascading-filter-based sampling.
@@ -72,17 +299,75 @@ type Config struct {
 	// DecisionWait is the desired wait time from the arrival of the first span of
 	// trace until the decision about sampling it or not is evaluated.
 	DecisionWait time.Duration `mapstructure:"decision_wait"`
+	// ErrorTraceDecisionWait (optional) is the shortened wait time applied to traces which have at least one span
+	// with error status. It must be shorter than DecisionWait and a whole number of seconds, 0 means it's not applied.
+	ErrorTraceDecisionWait time.Duration `mapstructure:"error_trace_decision_wait"`
+	// TickJitter (optional) randomizes the start of the decision ticks and their intervals (which are made every
+	// second) within this bound, so the ticks of multiple instances are not synchronized. It must be shorter than
+	// 1s, 0 means the ticks are not randomized.
+	TickJitter time.Duration `mapstructure:"tick_jitter"`
 	// SpansPerSecond specifies the total budget that should never be exceeded
 	SpansPerSecond int64 `mapstructure:"spans_per_second"`
-	// ProbabilisticFilteringRatio describes which part (0.0-1.0) of the SpansPerSecond budget
-	// is exclusively allocated for probabilistically selected spans
+	// SpansPerWindow (optional) replaces the SpansPerSecond global limit with the budget of spans for BudgetWindow.
+	// The budget is refilled gradually over the window, so bursts are allowed as long as it's not exceeded.
+	SpansPerWindow int64 `mapstructure:"spans_per_window"`
+	// BudgetWindow is the window of the SpansPerWindow budget, e.g. 1h or 24h. Default: 1h
+	BudgetWindow time.Duration `mapstructure:"budget_window"`
+	// ProbabilisticFilteringRatio describes which part (0.0-1.0) of the SpansPerSecond budget (or of the average
+	// rate of the SpansPerWindow budget, when set) is exclusively allocated for probabilistically selected spans
 	ProbabilisticFilteringRatio *float32 `mapstructure:"probabilistic_filtering_ratio"`
+	// ProbabilisticFilteringSizeBias makes the probabilistic filter prefer smaller (when positive) or larger
+	// (when negative) traces. The absolute value describes the strength of the bias, 0 means no bias.
+	ProbabilisticFilteringSizeBias float64 `mapstructure:"probabilistic_filtering_size_bias"`
+	// ProbabilisticFilteringAdvisory makes the probabilistic selection not final. Traces selected probabilistically
+	// which are also selected by a policy are attributed to the policy, the others are still kept.
+	ProbabilisticFilteringAdvisory bool `mapstructure:"probabilistic_filtering_advisory"`
+	// EmitPolicySamplingProbability determines if traces selected by policies (rather than the probabilistic filter)
+	// should have the effective sampling probability set as well. It's calculated as the part of spans of the traces
+	// matched by the policy which fit both its own limit and the global one (1.0 when none were left out).
+	EmitPolicySamplingProbability bool `mapstructure:"emit_policy_sampling_probability"`
+	// EmitDecisionTime determines if sampled traces should have the time of the tick which made the decision set
+	// as a resource attribute (in nanoseconds since the epoch). Default: false
+	EmitDecisionTime bool `mapstructure:"emit_decision_time"`
+	// ProbabilityAttributeKey (optional) is the span attribute holding the sampling probability, which is read
+	// and updated by the processor. Default: "sampling.probability"
+	ProbabilityAttributeKey string `mapstructure:"probability_attribute_key"`
 	// NumTraces is the number of traces kept on memory. Typically most of the data
 	// of a trace is released after a sampling decision is taken.
 	NumTraces uint64 `mapstructure:"num_traces"`
+	// MaxSpansPerTrace (optional) limits the number of spans kept for a single trace, spans exceeding it are dropped.
+	// 0 means no limit.
+	MaxSpansPerTrace int64 `mapstructure:"max_spans_per_trace"`
+	// PolicyEvaluationConcurrency (optional) is the maximum number of policies evaluated concurrently for a trace.
+	// 0 or 1 means the policies are evaluated sequentially.
+	PolicyEvaluationConcurrency int `mapstructure:"policy_evaluation_concurrency"`
 	// ExpectedNewTracesPerSec sets the expected number of new traces sending to the Cascading Filter processor
 	// per second. This helps with allocating data structures with closer to actual usage size.
 	ExpectedNewTracesPerSec uint64 `mapstructure:"expected_new_traces_per_sec"`
+	// MetricsExporter (optional) is the name of the metrics exporter, to which the sampling statistics
+	// are periodically sent as metrics.
+	MetricsExporter string `mapstructure:"metrics_exporter"`
+	// MetricsEmitInterval is the interval of sending the sampling statistics to MetricsExporter. Default: 1m
+	MetricsEmitInterval time.Duration `mapstructure:"metrics_emit_interval"`
+	// EffectiveRateWindow (optional) is the rolling window over which the part of the traces matched by each policy
+	// which were eventually sampled is calculated. When not set, it's not calculated.
+	EffectiveRateWindow time.Duration `mapstructure:"effective_rate_window"`
+	// DecisionLog (optional) enables recording the decisions to a file.
+	DecisionLog *DecisionLogCfg `mapstructure:"decision_log"`
+	// DroppedSample (optional) enables forwarding a sample of the dropped traces to a separate exporter.
+	DroppedSample *DroppedSampleCfg `mapstructure:"dropped_sample"`
+	// FastTrack (optional) is the policy which selects traces as soon as their arriving spans match it, without
+	// waiting for the DecisionWait. The remaining spans of such traces are forwarded as they arrive.
+	FastTrack *PolicyCfg `mapstructure:"fast_track"`
+	// PreFilter (optional) enables dropping or keeping whole traces by the resource attributes of their spans before
+	// any policy is evaluated.
+	PreFilter *PreFilterCfg `mapstructure:"pre_filter"`
+	// RequiredSpanAttributes (optional) are the keys of span attributes which must be all present, the spans missing
+	// any of them are dropped before they are buffered (so they are neither counted nor evaluated by policies).
+	RequiredSpanAttributes []string `mapstructure:"required_span_attributes"`
+	// FlushOnShutdown (optional) makes the final decision for all pending traces on shutdown, rather than dropping
+	// them, so the ones which would be sampled are still forwarded. Default: false
+	FlushOnShutdown bool `mapstructure:"flush_on_shutdown"`
 	// PolicyCfgs sets the cascading-filter-based sampling policy which makes a sampling decision
 	// for a given trace when requested.
 	PolicyCfgs []PolicyCfg `mapstructure:"policies"`
@@ -68,8 +68,9 @@ func TestLoadConfig(t *testing.T) {
 					StringAttributeCfg: &config.StringAttributeCfg{Key: "key2", Values: []string{"value1", "value2"}},
 				},
 				{
-					Name:           "test-policy-4",
-					SpansPerSecond: 35,
+					Name:                "test-policy-4",
+					SpansPerSecond:      35,
+					ReservedBudgetRatio: 0.1,
 				},
 				{
 					Name:           "test-policy-5",
@@ -0,0 +1,159 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+// decisionRecord is a single entry of the decision log.
+type decisionRecord struct {
+	TraceID        traceKey `json:"trace_id"`
+	SpanCount      int64    `json:"span_count"`
+	DurationMicros int64    `json:"duration_us"`
+	Policy         string   `json:"policy,omitempty"`
+	Decision       string   `json:"decision"`
+}
+
+// decisionLog writes a sample of decisions as JSON encoded records, one per line. The records are buffered and
+// written out once per decided batch.
+type decisionLog struct {
+	sync.Mutex
+	writer        io.WriteCloser
+	buffer        *bufio.Writer
+	encoder       *json.Encoder
+	closed        bool
+	samplingRatio float64
+	random        *rand.Rand
+}
+
+func newDecisionLog(cfg *config.DecisionLogCfg) (*decisionLog, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("decision log path must be provided")
+	}
+	if cfg.SamplingRatio <= 0.0 || cfg.SamplingRatio > 1.0 {
+		return nil, errors.New("decision log sampling ratio must be in (0.0, 1.0] range")
+	}
+
+	file, err := os.OpenFile(cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDecisionLogWithWriter(file, cfg.SamplingRatio), nil
+}
+
+func newDecisionLogWithWriter(writer io.WriteCloser, samplingRatio float64) *decisionLog {
+	buffer := bufio.NewWriter(writer)
+	return &decisionLog{
+		writer:        writer,
+		buffer:        buffer,
+		encoder:       json.NewEncoder(buffer),
+		samplingRatio: samplingRatio,
+		random:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// record writes the decision made for the trace, unless it's not selected by the sampling ratio
+func (dl *decisionLog) record(et evaluatedTrace, batches []pdata.Traces) error {
+	dl.Lock()
+	defer dl.Unlock()
+
+	if dl.closed {
+		return nil
+	}
+	if dl.samplingRatio < 1.0 && dl.random.Float64() >= dl.samplingRatio {
+		return nil
+	}
+
+	record := decisionRecord{
+		TraceID:        traceKey(et.id.Bytes()),
+		SpanCount:      et.trace.SpanCount,
+		DurationMicros: traceDurationMicros(batches),
+		Decision:       et.trace.FinalDecision.String(),
+	}
+	if et.matchingPolicy != nil {
+		record.Policy = et.matchingPolicy.Name
+	}
+
+	return dl.encoder.Encode(record)
+}
+
+// flush writes out the buffered records
+func (dl *decisionLog) flush() error {
+	dl.Lock()
+	defer dl.Unlock()
+
+	if dl.closed {
+		return nil
+	}
+	return dl.buffer.Flush()
+}
+
+func (dl *decisionLog) close() error {
+	dl.Lock()
+	defer dl.Unlock()
+
+	if dl.closed {
+		return nil
+	}
+	dl.closed = true
+	if err := dl.buffer.Flush(); err != nil {
+		_ = dl.writer.Close()
+		return err
+	}
+	return dl.writer.Close()
+}
+
+// traceDurationMicros returns the time between the earliest start and the latest end of the spans in given batches
+func traceDurationMicros(batches []pdata.Traces) int64 {
+	minStartTime := pdata.TimestampUnixNano(0)
+	maxEndTime := pdata.TimestampUnixNano(0)
+
+	for _, batch := range batches {
+		rs := batch.ResourceSpans()
+		for i := 0; i < rs.Len(); i++ {
+			ils := rs.At(i).InstrumentationLibrarySpans()
+			for j := 0; j < ils.Len(); j++ {
+				spans := ils.At(j).Spans()
+				for k := 0; k < spans.Len(); k++ {
+					span := spans.At(k)
+					if minStartTime == 0 || span.StartTime() < minStartTime {
+						minStartTime = span.StartTime()
+					}
+					if span.EndTime() > maxEndTime {
+						maxEndTime = span.EndTime()
+					}
+				}
+			}
+		}
+	}
+
+	if maxEndTime <= minStartTime {
+		return 0
+	}
+	return int64(maxEndTime-minStartTime) / 1000
+}
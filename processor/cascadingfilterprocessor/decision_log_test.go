@@ -0,0 +1,183 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/sampling"
+)
+
+func TestDecisionLogRecords(t *testing.T) {
+	cases := []struct {
+		Desc            string
+		Decision        sampling.Decision
+		ExpectedRecords []decisionRecord
+	}{
+		{
+			Desc:     "selected by policy",
+			Decision: sampling.Sampled,
+			// The last trace does not fit the global limit
+			ExpectedRecords: []decisionRecord{
+				{SpanCount: 1, Policy: "mock-policy", Decision: "Sampled"},
+				{SpanCount: 2, Policy: "mock-policy", Decision: "Sampled"},
+				{SpanCount: 3, Policy: "mock-policy", Decision: "NotSampled"},
+			},
+		},
+		{
+			Desc:     "not selected",
+			Decision: sampling.NotSampled,
+			ExpectedRecords: []decisionRecord{
+				{SpanCount: 1, Decision: "NotSampled"},
+				{SpanCount: 2, Decision: "NotSampled"},
+				{SpanCount: 3, Decision: "NotSampled"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			tsp := newTestProcessor(t,
+				withPolicies(&Policy{Name: "mock-policy", Evaluator: &mockPolicyEvaluator{NextDecision: c.Decision}, ctx: context.TODO()}),
+				withMaxSpansPerSecond(3),
+				func(cfsp *cascadingFilterSpanProcessor) {
+					cfsp.decisionLog = newDecisionLogWithWriter(nopWriteCloser{buf}, 1.0)
+				},
+			)
+
+			traceIds, batches := generateIdsAndBatches(3)
+			for _, batch := range batches {
+				require.NoError(t, tsp.ConsumeTraces(context.Background(), batch))
+			}
+
+			tsp.samplingPolicyOnTick()
+			tsp.samplingPolicyOnTick()
+			require.NoError(t, tsp.Shutdown(context.Background()))
+
+			records := readDecisionRecords(t, buf)
+			require.Len(t, records, len(c.ExpectedRecords))
+			for i, expected := range c.ExpectedRecords {
+				expected.TraceID = traceKey(traceIds[i].Bytes())
+				assert.Equal(t, expected, records[i])
+			}
+		})
+	}
+}
+
+func TestDecisionLogSamplingRatio(t *testing.T) {
+	buf := &bytes.Buffer{}
+	dl := newDecisionLogWithWriter(nopWriteCloser{buf}, 0.25)
+	dl.random = rand.New(rand.NewSource(1))
+
+	et := evaluatedTrace{
+		id:    pdata.NewTraceID([16]byte{1, 2, 3, 4}),
+		trace: &sampling.TraceData{SpanCount: 1, FinalDecision: sampling.NotSampled},
+	}
+	for i := 0; i < 10000; i++ {
+		require.NoError(t, dl.record(et, nil))
+	}
+	require.NoError(t, dl.flush())
+
+	assert.InDelta(t, 2500, len(readDecisionRecords(t, buf)), 200)
+}
+
+func TestDecisionLogIsBuffered(t *testing.T) {
+	buf := &bytes.Buffer{}
+	dl := newDecisionLogWithWriter(nopWriteCloser{buf}, 1.0)
+
+	et := evaluatedTrace{
+		id:    pdata.NewTraceID([16]byte{1, 2, 3, 4}),
+		trace: &sampling.TraceData{SpanCount: 1, FinalDecision: sampling.Sampled},
+	}
+	require.NoError(t, dl.record(et, nil))
+	assert.Zero(t, buf.Len())
+
+	require.NoError(t, dl.flush())
+	assert.Len(t, readDecisionRecords(t, buf), 1)
+
+	// The records are written out on close, while the ones made afterwards are dropped
+	require.NoError(t, dl.record(et, nil))
+	require.NoError(t, dl.close())
+	require.NoError(t, dl.record(et, nil))
+	require.NoError(t, dl.flush())
+	assert.Len(t, readDecisionRecords(t, buf), 1)
+}
+
+func TestDecisionLogConfig(t *testing.T) {
+	_, err := newDecisionLog(&config.DecisionLogCfg{Path: "", SamplingRatio: 1.0})
+	assert.Error(t, err)
+
+	_, err = newDecisionLog(&config.DecisionLogCfg{Path: "decisions.log", SamplingRatio: 0})
+	assert.Error(t, err)
+
+	file, err := ioutil.TempFile("", "decisions")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	defer os.Remove(file.Name())
+
+	dl, err := newDecisionLog(&config.DecisionLogCfg{Path: file.Name(), SamplingRatio: 1.0})
+	require.NoError(t, err)
+	require.NoError(t, dl.close())
+}
+
+func TestTraceDurationMicros(t *testing.T) {
+	now := time.Now().UnixNano()
+	traces := pdata.NewTraces()
+	traces.ResourceSpans().Resize(1)
+	rs := traces.ResourceSpans().At(0)
+	rs.InstrumentationLibrarySpans().Resize(1)
+	spans := rs.InstrumentationLibrarySpans().At(0).Spans()
+	spans.Resize(2)
+	spans.At(0).SetStartTime(pdata.TimestampUnixNano(now))
+	spans.At(0).SetEndTime(pdata.TimestampUnixNano(now + 1000000))
+	spans.At(1).SetStartTime(pdata.TimestampUnixNano(now + 500000))
+	spans.At(1).SetEndTime(pdata.TimestampUnixNano(now + 3000000))
+
+	assert.EqualValues(t, 3000, traceDurationMicros([]pdata.Traces{traces}))
+	assert.EqualValues(t, 0, traceDurationMicros(nil))
+}
+
+func readDecisionRecords(t *testing.T, buf *bytes.Buffer) []decisionRecord {
+	var records []decisionRecord
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var record decisionRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+type nopWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configmodels"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+// droppedSample forwards a sample of the traces that were not sampled to a separate consumer, for auditing.
+type droppedSample struct {
+	sync.Mutex
+	// exporterName is the name of the traces exporter, it's resolved into consumer on start
+	exporterName  string
+	consumer      consumer.TracesConsumer
+	samplingRatio float64
+	random        *rand.Rand
+}
+
+func newDroppedSample(cfg *config.DroppedSampleCfg) (*droppedSample, error) {
+	if cfg.Exporter == "" {
+		return nil, errors.New("dropped sample exporter must be provided")
+	}
+	if cfg.SamplingRatio <= 0.0 || cfg.SamplingRatio > 1.0 {
+		return nil, errors.New("dropped sample sampling ratio must be in (0.0, 1.0] range")
+	}
+
+	return &droppedSample{
+		exporterName:  cfg.Exporter,
+		samplingRatio: cfg.SamplingRatio,
+		random:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// findTracesExporter looks up the traces exporter with given name among the exporters available in the host
+func findTracesExporter(host component.Host, name string) (consumer.TracesConsumer, error) {
+	for exporterCfg, exporter := range host.GetExporters()[configmodels.TracesDataType] {
+		if exporterCfg.Name() != name {
+			continue
+		}
+		tracesExporter, ok := exporter.(component.TracesExporter)
+		if !ok {
+			return nil, fmt.Errorf("%s exporter is not a traces exporter", name)
+		}
+		return tracesExporter, nil
+	}
+	return nil, fmt.Errorf("traces exporter %s is not configured in any traces pipeline", name)
+}
+
+// forward sends the dropped trace to the consumer, unless it's not selected by the sampling ratio. The spans
+// are annotated with the dropped sample rule and the probability of being forwarded
+func (ds *droppedSample) forward(ctx context.Context, batches []pdata.Traces, probabilityKey string, logger *zap.Logger) {
+	ds.Lock()
+	selected := ds.samplingRatio >= 1.0 || ds.random.Float64() < ds.samplingRatio
+	ds.Unlock()
+	if !selected || len(batches) == 0 {
+		return
+	}
+
+	allSpans := combineBatches(batches)
+	updateDroppedSampleTag(allSpans, probabilityKey, ds.samplingRatio)
+	if err := ds.consumer.ConsumeTraces(ctx, allSpans); err != nil {
+		logger.Warn("Error forwarding dropped sample", zap.Error(err))
+	}
+}
+
+func updateDroppedSampleTag(traces pdata.Traces, probabilityKey string, ratio float64) {
+	rs := traces.ResourceSpans()
+
+	for i := 0; i < rs.Len(); i++ {
+		ils := rs.At(i).InstrumentationLibrarySpans()
+		for j := 0; j < ils.Len(); j++ {
+			spans := ils.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				attrs := spans.At(k).Attributes()
+				updateSamplingProbability(attrs, probabilityKey, ratio)
+				attrs.UpsertString(AttributeSamplingRule, droppedSampleRuleValue)
+			}
+		}
+	}
+}
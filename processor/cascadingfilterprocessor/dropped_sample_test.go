@@ -0,0 +1,123 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/translator/conventions"
+	tracetranslator "go.opentelemetry.io/collector/translator/trace"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/sampling"
+)
+
+func TestDroppedSampleFraction(t *testing.T) {
+	const maxSize = 10000
+	const numTraces = 4000
+	msp := new(consumertest.TracesSink)
+	auditSink := new(consumertest.TracesSink)
+	ds, err := newDroppedSample(&config.DroppedSampleCfg{Exporter: "otlp/audit", SamplingRatio: 0.25})
+	require.NoError(t, err)
+	ds.consumer = auditSink
+	ds.random = rand.New(rand.NewSource(1))
+
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: &mockPolicyEvaluator{NextDecision: sampling.NotSampled}, ctx: context.TODO()}),
+		withMaxNumTraces(maxSize),
+		func(cfsp *cascadingFilterSpanProcessor) {
+			cfsp.droppedSample = ds
+		},
+	)
+
+	for i := 0; i < numTraces; i++ {
+		traceID := tracetranslator.UInt64ToTraceID(1, uint64(i+1))
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(traceID, 1)))
+	}
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	require.Equal(t, 0, msp.SpansCount(), "dropped traces must not reach the next consumer")
+	assert.InDelta(t, numTraces/4, len(auditSink.AllTraces()), 100)
+
+	for _, trace := range auditSink.AllTraces() {
+		for _, attrs := range collectSpanAttributes(&trace) {
+			rule, ok := attrs.Get(AttributeSamplingRule)
+			require.True(t, ok)
+			require.Equal(t, droppedSampleRuleValue, rule.StringVal())
+			probability, ok := attrs.Get(conventions.AttributeSamplingProbability)
+			require.True(t, ok)
+			require.Equal(t, 0.25, probability.DoubleVal())
+		}
+	}
+}
+
+func TestDroppedSampleSkipsSampledTraces(t *testing.T) {
+	msp := new(consumertest.TracesSink)
+	auditSink := new(consumertest.TracesSink)
+	ds, err := newDroppedSample(&config.DroppedSampleCfg{Exporter: "otlp/audit", SamplingRatio: 1.0})
+	require.NoError(t, err)
+	ds.consumer = auditSink
+
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: &mockPolicyEvaluator{NextDecision: sampling.Sampled}, ctx: context.TODO()}),
+		withMaxSpansPerSecond(2),
+		func(cfsp *cascadingFilterSpanProcessor) {
+			cfsp.droppedSample = ds
+		},
+	)
+
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(pdata.NewTraceID([16]byte{1}), 2)))
+	// The second trace exceeds the global limit, so it's dropped
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(pdata.NewTraceID([16]byte{2}), 1)))
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	require.Equal(t, 2, msp.SpansCount())
+	require.Len(t, auditSink.AllTraces(), 1)
+	require.NotNil(t, findTrace(auditSink.AllTraces(), pdata.NewTraceID([16]byte{2})))
+}
+
+func TestDroppedSampleConfig(t *testing.T) {
+	_, err := newDroppedSample(&config.DroppedSampleCfg{Exporter: "", SamplingRatio: 0.1})
+	assert.Error(t, err)
+
+	_, err = newDroppedSample(&config.DroppedSampleCfg{Exporter: "otlp/audit", SamplingRatio: 0})
+	assert.Error(t, err)
+
+	_, err = newDroppedSample(&config.DroppedSampleCfg{Exporter: "otlp/audit", SamplingRatio: 1.5})
+	assert.Error(t, err)
+}
+
+func TestStartFailsWithoutDroppedSampleExporter(t *testing.T) {
+	ds, err := newDroppedSample(&config.DroppedSampleCfg{Exporter: "otlp/missing", SamplingRatio: 0.1})
+	require.NoError(t, err)
+	cfsp := &cascadingFilterSpanProcessor{
+		logger:        zap.NewNop(),
+		droppedSample: ds,
+	}
+
+	require.Error(t, cfsp.Start(context.Background(), componenttest.NewNopHost()))
+}
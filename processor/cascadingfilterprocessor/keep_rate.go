@@ -0,0 +1,76 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"sync"
+	"time"
+)
+
+// keepRateBucket holds the number of matched and kept traces in a given second
+type keepRateBucket struct {
+	second  int64
+	matched int64
+	kept    int64
+}
+
+// keepRateWindow tracks the part of the traces matched by a policy which were eventually sampled,
+// over a rolling window of seconds
+type keepRateWindow struct {
+	sync.Mutex
+	buckets []keepRateBucket
+}
+
+func newKeepRateWindow(window time.Duration) *keepRateWindow {
+	seconds := int(window / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &keepRateWindow{buckets: make([]keepRateBucket, seconds)}
+}
+
+// add accounts the matched and kept traces in the given second
+func (w *keepRateWindow) add(second int64, matched int64, kept int64) {
+	w.Lock()
+	defer w.Unlock()
+
+	bucket := &w.buckets[second%int64(len(w.buckets))]
+	if bucket.second != second {
+		*bucket = keepRateBucket{second: second}
+	}
+	bucket.matched += matched
+	bucket.kept += kept
+}
+
+// rate returns the part of the traces matched within the window ending at the given second which were kept.
+// It returns false if no traces were matched
+func (w *keepRateWindow) rate(second int64) (float64, bool) {
+	w.Lock()
+	defer w.Unlock()
+
+	matched := int64(0)
+	kept := int64(0)
+	oldest := second - int64(len(w.buckets))
+	for _, bucket := range w.buckets {
+		if bucket.second > oldest && bucket.second <= second {
+			matched += bucket.matched
+			kept += bucket.kept
+		}
+	}
+	if matched == 0 {
+		return 0, false
+	}
+	return float64(kept) / float64(matched), true
+}
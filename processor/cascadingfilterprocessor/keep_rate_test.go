@@ -0,0 +1,49 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepRateWindow(t *testing.T) {
+	w := newKeepRateWindow(3 * time.Second)
+
+	_, ok := w.rate(100)
+	require.False(t, ok, "no traces were matched yet")
+
+	w.add(100, 4, 1)
+	w.add(101, 4, 3)
+	rate, ok := w.rate(101)
+	require.True(t, ok)
+	require.Equal(t, 0.5, rate)
+
+	w.add(102, 2, 2)
+	rate, ok = w.rate(102)
+	require.True(t, ok)
+	require.Equal(t, 6.0/10.0, rate)
+
+	// The second 100 falls out of the window, while 103 reuses its bucket
+	w.add(103, 2, 0)
+	rate, ok = w.rate(103)
+	require.True(t, ok)
+	require.Equal(t, 5.0/8.0, rate)
+
+	_, ok = w.rate(110)
+	require.False(t, ok, "all matches are outside of the window")
+}
@@ -28,6 +28,9 @@ var (
 	statusNotSampled           = "NotSampled"
 	statusExceededKey          = "RateExceeded"
 	statusSecondChance         = "SecondChance"
+	statusDrop                 = "Drop"
+	statusFastTracked          = "FastTracked"
+	statusForceKept            = "ForceKept"
 	statusSecondChanceSampled  = "SecondChanceSampled"
 	statusSecondChanceExceeded = "SecondChanceRateExceeded"
 
@@ -39,6 +42,7 @@ var (
 	statOverallDecisionLatencyus = stats.Int64("cascading_filtering_batch_processing_latency", "Latency (in microseconds) of each run of the cascading filter timer", "µs")
 
 	statTraceRemovalAgeSec           = stats.Int64("cascading_trace_removal_age", "Time (in seconds) from arrival of a new trace until its removal from memory", "s")
+	statTraceAgeAtDecisionSec        = stats.Int64("cascading_trace_age_at_decision", "Time (in seconds) from arrival of a new trace until the cascading filter decision was taken", "s")
 	statLateSpanArrivalAfterDecision = stats.Int64("cascadind_late_span_age", "Time (in seconds) from the cascading filter decision was taken and the arrival of a late span", "s")
 
 	statPolicyEvaluationErrorCount = stats.Int64("cascading_policy_evaluation_error", "Count of cascading policy evaluation errors", stats.UnitDimensionless)
@@ -46,9 +50,17 @@ var (
 	statCascadingFilterDecision = stats.Int64("count_final_decision", "Count of traces that were filtered or not", stats.UnitDimensionless)
 	statPolicyDecision          = stats.Int64("count_policy_decision", "Count of provisional (policy) decisions if traces were filtered or not", stats.UnitDimensionless)
 
-	statDroppedTooEarlyCount    = stats.Int64("casdading_trace_dropped_too_early", "Count of traces that needed to be dropped the configured wait time", stats.UnitDimensionless)
-	statNewTraceIDReceivedCount = stats.Int64("cascading_new_trace_id_received", "Counts the arrival of new traces", stats.UnitDimensionless)
-	statTracesOnMemoryGauge     = stats.Int64("cascading_traces_on_memory", "Tracks the number of traces current on memory", stats.UnitDimensionless)
+	statDroppedTooEarlyCount     = stats.Int64("casdading_trace_dropped_too_early", "Count of traces that needed to be dropped the configured wait time", stats.UnitDimensionless)
+	statNewTraceIDReceivedCount  = stats.Int64("cascading_new_trace_id_received", "Counts the arrival of new traces", stats.UnitDimensionless)
+	statSpansOverTraceLimitCount = stats.Int64("cascading_spans_dropped_over_trace_limit", "Count of spans dropped as their traces exceeded the max number of spans", stats.UnitDimensionless)
+	statTracesOnMemoryGauge      = stats.Int64("cascading_traces_on_memory", "Tracks the number of traces current on memory", stats.UnitDimensionless)
+
+	statRemainingSpansPerSecondGauge = stats.Int64("cascading_remaining_spans_per_second", "Tracks the number of spans that still fit the global limit in the current second", stats.UnitDimensionless)
+	statPolicyEffectiveKeepRate      = stats.Float64("cascading_policy_effective_keep_rate", "Part of the traces matched by the policy which were sampled within the recent window", stats.UnitDimensionless)
+	statSpansDroppedByPreFilterCount = stats.Int64("cascading_spans_dropped_by_pre_filter", "Count of spans dropped by the pre-filter before being buffered", stats.UnitDimensionless)
+	statSpansOverRateLimitCount      = stats.Int64("cascading_spans_dropped_over_rate_limit", "Count of spans of selected traces dropped as they exceeded the global limit of spans per second", stats.UnitDimensionless)
+	statSpansMissingRequiredAttrs    = stats.Int64("cascading_spans_dropped_missing_required_attributes", "Count of spans dropped as they missed any of the required attributes", stats.UnitDimensionless)
+	statTracesEvictedBeforeDecision  = stats.Int64("cascading_traces_evicted_before_decision", "Count of traces removed from memory to make room for new ones before the decision was made", stats.UnitDimensionless)
 )
 
 // CascadingFilterMetricViews return the metrics views according to given telemetry level.
@@ -74,6 +86,13 @@ func CascadingFilterMetricViews(level configtelemetry.Level) []*view.View {
 		Aggregation: ageDistributionAggregation,
 	}
 
+	traceAgeAtDecisionView := &view.View{
+		Name:        statTraceAgeAtDecisionSec.Name(),
+		Measure:     statTraceAgeAtDecisionSec,
+		Description: statTraceAgeAtDecisionSec.Description(),
+		Aggregation: ageDistributionAggregation,
+	}
+
 	lateSpanArrivalView := &view.View{
 		Name:        statLateSpanArrivalAfterDecision.Name(),
 		Measure:     statLateSpanArrivalAfterDecision,
@@ -85,6 +104,7 @@ func CascadingFilterMetricViews(level configtelemetry.Level) []*view.View {
 		Name:        statPolicyEvaluationErrorCount.Name(),
 		Measure:     statPolicyEvaluationErrorCount,
 		Description: statPolicyEvaluationErrorCount.Description(),
+		TagKeys:     []tag.Key{tagPolicyKey},
 		Aggregation: view.Sum(),
 	}
 
@@ -124,16 +144,60 @@ func CascadingFilterMetricViews(level configtelemetry.Level) []*view.View {
 		Description: statNewTraceIDReceivedCount.Description(),
 		Aggregation: view.Sum(),
 	}
+	countSpansOverTraceLimitView := &view.View{
+		Name:        statSpansOverTraceLimitCount.Name(),
+		Measure:     statSpansOverTraceLimitCount,
+		Description: statSpansOverTraceLimitCount.Description(),
+		Aggregation: view.Sum(),
+	}
 	trackTracesOnMemorylView := &view.View{
 		Name:        statTracesOnMemoryGauge.Name(),
 		Measure:     statTracesOnMemoryGauge,
 		Description: statTracesOnMemoryGauge.Description(),
 		Aggregation: view.LastValue(),
 	}
+	trackRemainingSpansPerSecondView := &view.View{
+		Name:        statRemainingSpansPerSecondGauge.Name(),
+		Measure:     statRemainingSpansPerSecondGauge,
+		Description: statRemainingSpansPerSecondGauge.Description(),
+		Aggregation: view.LastValue(),
+	}
+	trackPolicyEffectiveKeepRateView := &view.View{
+		Name:        statPolicyEffectiveKeepRate.Name(),
+		Measure:     statPolicyEffectiveKeepRate,
+		Description: statPolicyEffectiveKeepRate.Description(),
+		TagKeys:     []tag.Key{tagPolicyKey},
+		Aggregation: view.LastValue(),
+	}
+	countSpansDroppedByPreFilterView := &view.View{
+		Name:        statSpansDroppedByPreFilterCount.Name(),
+		Measure:     statSpansDroppedByPreFilterCount,
+		Description: statSpansDroppedByPreFilterCount.Description(),
+		Aggregation: view.Sum(),
+	}
+	countSpansOverRateLimitView := &view.View{
+		Name:        statSpansOverRateLimitCount.Name(),
+		Measure:     statSpansOverRateLimitCount,
+		Description: statSpansOverRateLimitCount.Description(),
+		Aggregation: view.Sum(),
+	}
+	countSpansMissingRequiredAttrsView := &view.View{
+		Name:        statSpansMissingRequiredAttrs.Name(),
+		Measure:     statSpansMissingRequiredAttrs,
+		Description: statSpansMissingRequiredAttrs.Description(),
+		Aggregation: view.Sum(),
+	}
+	countTracesEvictedBeforeDecisionView := &view.View{
+		Name:        statTracesEvictedBeforeDecision.Name(),
+		Measure:     statTracesEvictedBeforeDecision,
+		Description: statTracesEvictedBeforeDecision.Description(),
+		Aggregation: view.Sum(),
+	}
 
 	legacyViews := []*view.View{
 		overallDecisionLatencyView,
 		traceRemovalAgeView,
+		traceAgeAtDecisionView,
 		lateSpanArrivalView,
 
 		countPolicyDecisionsView,
@@ -143,7 +207,14 @@ func CascadingFilterMetricViews(level configtelemetry.Level) []*view.View {
 		countPolicyEvaluationErrorView,
 		countTraceDroppedTooEarlyView,
 		countTraceIDArrivalView,
+		countSpansOverTraceLimitView,
 		trackTracesOnMemorylView,
+		trackRemainingSpansPerSecondView,
+		countSpansOverRateLimitView,
+		countSpansDroppedByPreFilterView,
+		trackPolicyEffectiveKeepRateView,
+		countTracesEvictedBeforeDecisionView,
+		countSpansMissingRequiredAttrsView,
 	}
 
 	return obsreport.ProcessorMetricViews(typeStr, legacyViews)
@@ -0,0 +1,66 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"fmt"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+// policyInteractionWarnings returns the warnings about policy settings which are valid, but likely to interact in
+// a surprising way with the global limit. The traces are evaluated in two runs:
+//   - the traces selected by policies are accounted first, against the limit of the policy and then the global one
+//   - the "SecondChance" traces (of policies with spans_per_second set to -1, or exceeding the limit of policies with
+//     second_chance_on_rate_limit enabled) share whatever is left of the global limit afterwards, the ones of
+//     policies with higher priority first
+func policyInteractionWarnings(cfg config.Config) []string {
+	var warnings []string
+
+	selectingSpansPerSecond := int64(0)
+	hasSecondChance := false
+	for i := range cfg.PolicyCfgs {
+		policyCfg := &cfg.PolicyCfgs[i]
+		if policyCfg.Drop {
+			// The traces of such policies are not accounted against any limit
+			continue
+		}
+
+		if policyCfg.SpansPerSecond < 0 {
+			hasSecondChance = true
+			if policyCfg.ReservedBudgetRatio > 0 {
+				warnings = append(warnings, fmt.Sprintf("policy %s gives \"SecondChance\" to the traces, which cannot use "+
+					"its reserved budget, as they share only the unreserved part of the global limit", policyCfg.Name))
+			}
+			continue
+		}
+
+		if policyCfg.SecondChanceOnRateLimit {
+			hasSecondChance = true
+		} else if policyCfg.Priority != 0 {
+			warnings = append(warnings, fmt.Sprintf("priority of policy %s has no effect, as it applies only to "+
+				"policies giving \"SecondChance\" to the traces (with spans_per_second set to -1 or "+
+				"second_chance_on_rate_limit enabled)", policyCfg.Name))
+		}
+		selectingSpansPerSecond += policyCfg.SpansPerSecond
+	}
+
+	if hasSecondChance && cfg.SpansPerWindow == 0 && selectingSpansPerSecond >= cfg.SpansPerSecond {
+		warnings = append(warnings, fmt.Sprintf("policies might select %d spans per second, which is not less than the "+
+			"global limit of %d, so the \"SecondChance\" traces might be never sampled", selectingSpansPerSecond, cfg.SpansPerSecond))
+	}
+
+	return warnings
+}
@@ -0,0 +1,172 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/sampling"
+)
+
+func TestPolicyInteractionWarnings(t *testing.T) {
+	cases := []struct {
+		Desc             string
+		Policies         []config.PolicyCfg
+		ExpectedWarnings int
+	}{
+		{
+			Desc: "no interactions",
+			Policies: []config.PolicyCfg{
+				{Name: "selecting", SpansPerSecond: 500},
+				{Name: "second-chance", SpansPerSecond: -1, Priority: 10},
+			},
+		},
+		{
+			Desc:     "no second chance policies",
+			Policies: []config.PolicyCfg{{Name: "selecting", SpansPerSecond: 5000}},
+		},
+		{
+			Desc: "second chance policy with reserved budget",
+			Policies: []config.PolicyCfg{
+				{Name: "second-chance", SpansPerSecond: -1, ReservedBudgetRatio: 0.1},
+			},
+			ExpectedWarnings: 1,
+		},
+		{
+			Desc: "priority of selecting policy",
+			Policies: []config.PolicyCfg{
+				{Name: "selecting", SpansPerSecond: 500, Priority: 10},
+			},
+			ExpectedWarnings: 1,
+		},
+		{
+			Desc: "priority of policy giving second chance on rate limit",
+			Policies: []config.PolicyCfg{
+				{Name: "selecting", SpansPerSecond: 500, SecondChanceOnRateLimit: true, Priority: 10},
+			},
+		},
+		{
+			Desc: "selecting policies taking the whole global limit",
+			Policies: []config.PolicyCfg{
+				{Name: "selecting-1", SpansPerSecond: 600},
+				{Name: "selecting-2", SpansPerSecond: 400},
+				{Name: "second-chance", SpansPerSecond: -1},
+			},
+			ExpectedWarnings: 1,
+		},
+		{
+			Desc: "drop policies are not accounted",
+			Policies: []config.PolicyCfg{
+				{Name: "drop", SpansPerSecond: 5000, Priority: 10, Drop: true},
+				{Name: "second-chance", SpansPerSecond: -1},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			warnings := policyInteractionWarnings(config.Config{SpansPerSecond: 1000, PolicyCfgs: c.Policies})
+			assert.Len(t, warnings, c.ExpectedWarnings)
+		})
+	}
+}
+
+func TestRateLimitedPolicyGivesWayToSecondChance(t *testing.T) {
+	fittingTraceID := pdata.NewTraceID([16]byte{1})
+	rateLimitedTraceID := pdata.NewTraceID([16]byte{2})
+
+	msp := new(consumertest.TracesSink)
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		// Both traces match the policies, but only the first one fits the limit of the selecting policy
+		withPolicies(
+			&Policy{Name: "rate-limited", Evaluator: &budgetPolicyEvaluator{budget: 1}, ctx: context.TODO()},
+			&Policy{Name: "second-chance", Evaluator: &mockPolicyEvaluator{NextDecision: sampling.SecondChance}, ctx: context.TODO()},
+		),
+		withMaxSpansPerSecond(10),
+	)
+
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(fittingTraceID, 3)))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(rateLimitedTraceID, 3)))
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	fittingTrace, ok := tsp.idToTrace.Load(traceKey(fittingTraceID.Bytes()))
+	require.True(t, ok)
+	require.Equal(t, []sampling.Decision{sampling.Sampled, sampling.SecondChance}, fittingTrace.(*sampling.TraceData).Decisions)
+
+	// The trace exceeding the limit of the selecting policy is kept by the "SecondChance" policy
+	rateLimitedTrace, ok := tsp.idToTrace.Load(traceKey(rateLimitedTraceID.Bytes()))
+	require.True(t, ok)
+	require.Equal(t, []sampling.Decision{sampling.NotSampled, sampling.SecondChance}, rateLimitedTrace.(*sampling.TraceData).Decisions)
+	require.Equal(t, sampling.Sampled, rateLimitedTrace.(*sampling.TraceData).FinalDecision)
+
+	require.Equal(t, 6, msp.SpansCount())
+}
+
+func TestSelectedTraceOverGlobalLimitGetsNoSecondChance(t *testing.T) {
+	smallTraceID := pdata.NewTraceID([16]byte{1})
+	largeTraceID := pdata.NewTraceID([16]byte{2})
+
+	msp := new(consumertest.TracesSink)
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(
+			&Policy{Name: "selecting", Evaluator: newSelectingPolicyEvaluator(sampling.Sampled, largeTraceID), ctx: context.TODO()},
+			&Policy{Name: "second-chance", Evaluator: &mockPolicyEvaluator{NextDecision: sampling.SecondChance}, ctx: context.TODO()},
+		),
+		withMaxSpansPerSecond(10),
+	)
+
+	// The large trace is selected, but does not fit the global limit, which is then left for the small one
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(smallTraceID, 5)))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(largeTraceID, 20)))
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	largeTrace, ok := tsp.idToTrace.Load(traceKey(largeTraceID.Bytes()))
+	require.True(t, ok)
+	require.Equal(t, sampling.NotSampled, largeTrace.(*sampling.TraceData).FinalDecision)
+
+	require.Equal(t, 5, msp.SpansCount())
+	require.NotNil(t, findTrace(msp.AllTraces(), smallTraceID))
+}
+
+func TestSecondChanceOfHighestPriorityPolicy(t *testing.T) {
+	lowPriority := &Policy{Name: "low-priority", Evaluator: &mockPolicyEvaluator{NextDecision: sampling.SecondChance}, ctx: context.TODO(), priority: 1}
+	highPriority := &Policy{Name: "high-priority", Evaluator: &mockPolicyEvaluator{NextDecision: sampling.SecondChance}, ctx: context.TODO(), priority: 10}
+	samePriority := &Policy{Name: "same-priority", Evaluator: &mockPolicyEvaluator{NextDecision: sampling.SecondChance}, ctx: context.TODO(), priority: 10}
+	tsp := newTestProcessor(t,
+		withPolicies(
+			lowPriority,
+			highPriority,
+			samePriority,
+		),
+		withMaxSpansPerSecond(10),
+	)
+
+	trace := &sampling.TraceData{Decisions: make([]sampling.Decision, len(tsp.policies)), SpanCount: 1}
+	decision, policy := tsp.makeProvisionalDecision(pdata.NewTraceID([16]byte{1}), trace)
+	require.Equal(t, sampling.SecondChance, decision)
+	// The first policy of the highest priority is returned, regardless of the order of policies
+	require.Same(t, highPriority, policy)
+}
@@ -0,0 +1,108 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+// resourceAttributeMatcher matches resources having the string attribute equal to any of the values
+type resourceAttributeMatcher struct {
+	key    string
+	values map[string]struct{}
+}
+
+// preFilterDecision is the verdict of the pre-filter for the spans of a resource, which applies to their whole traces
+type preFilterDecision int
+
+const (
+	// preFilterNoMatch leaves the trace to the policies
+	preFilterNoMatch preFilterDecision = iota
+	// preFilterDrop drops the trace before it is buffered
+	preFilterDrop
+	// preFilterKeep samples the trace right away, without evaluating the policies
+	preFilterKeep
+)
+
+// preFilter decides if the traces having spans of a resource are dropped or kept, before they are buffered for
+// policy evaluation
+type preFilter struct {
+	forceKeep []resourceAttributeMatcher
+	allow     []resourceAttributeMatcher
+	deny      []resourceAttributeMatcher
+}
+
+func newPreFilter(cfg *config.PreFilterCfg) (*preFilter, error) {
+	forceKeep, err := newResourceAttributeMatchers(cfg.ForceKeep)
+	if err != nil {
+		return nil, err
+	}
+	allow, err := newResourceAttributeMatchers(cfg.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := newResourceAttributeMatchers(cfg.Deny)
+	if err != nil {
+		return nil, err
+	}
+	return &preFilter{forceKeep: forceKeep, allow: allow, deny: deny}, nil
+}
+
+func newResourceAttributeMatchers(cfgs []config.ResourceAttributeCfg) ([]resourceAttributeMatcher, error) {
+	matchers := make([]resourceAttributeMatcher, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		if cfg.Key == "" {
+			return nil, errors.New("pre-filter resource attribute key must be provided")
+		}
+		if len(cfg.Values) == 0 {
+			return nil, errors.New("pre-filter resource attribute values must be provided")
+		}
+		values := make(map[string]struct{}, len(cfg.Values))
+		for _, value := range cfg.Values {
+			values[value] = struct{}{}
+		}
+		matchers = append(matchers, resourceAttributeMatcher{key: cfg.Key, values: values})
+	}
+	return matchers, nil
+}
+
+// decide returns preFilterKeep if the resource matches any of the force keep matchers, preFilterDrop if it matches
+// any of the deny matchers or, when there are allow matchers, if it matches none of them, and preFilterNoMatch
+// otherwise
+func (pf *preFilter) decide(resource pdata.Resource) preFilterDecision {
+	attrs := resource.Attributes()
+	if matchesAny(attrs, pf.forceKeep) {
+		return preFilterKeep
+	}
+	if matchesAny(attrs, pf.deny) || (len(pf.allow) > 0 && !matchesAny(attrs, pf.allow)) {
+		return preFilterDrop
+	}
+	return preFilterNoMatch
+}
+
+func matchesAny(attrs pdata.AttributeMap, matchers []resourceAttributeMatcher) bool {
+	for _, matcher := range matchers {
+		if v, ok := attrs.Get(matcher.key); ok && v.Type() == pdata.AttributeValueSTRING {
+			if _, found := matcher.values[v.StringVal()]; found {
+				return true
+			}
+		}
+	}
+	return false
+}
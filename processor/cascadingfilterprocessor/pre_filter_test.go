@@ -0,0 +1,219 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/sampling"
+)
+
+func TestPreFilterDecide(t *testing.T) {
+	cases := []struct {
+		Desc     string
+		Cfg      config.PreFilterCfg
+		Tenant   string
+		Expected preFilterDecision
+	}{
+		{
+			Desc:     "no matchers",
+			Cfg:      config.PreFilterCfg{},
+			Tenant:   "tenant-a",
+			Expected: preFilterNoMatch,
+		},
+		{
+			Desc:     "denied",
+			Cfg:      config.PreFilterCfg{Deny: []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"tenant-a"}}}},
+			Tenant:   "tenant-a",
+			Expected: preFilterDrop,
+		},
+		{
+			Desc:     "not denied",
+			Cfg:      config.PreFilterCfg{Deny: []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"tenant-a"}}}},
+			Tenant:   "tenant-b",
+			Expected: preFilterNoMatch,
+		},
+		{
+			Desc:     "allowed",
+			Cfg:      config.PreFilterCfg{Allow: []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"tenant-a", "tenant-b"}}}},
+			Tenant:   "tenant-b",
+			Expected: preFilterNoMatch,
+		},
+		{
+			Desc:     "not allowed",
+			Cfg:      config.PreFilterCfg{Allow: []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"tenant-a"}}}},
+			Tenant:   "tenant-c",
+			Expected: preFilterDrop,
+		},
+		{
+			Desc: "deny takes precedence",
+			Cfg: config.PreFilterCfg{
+				Allow: []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"tenant-a"}}},
+				Deny:  []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"tenant-a"}}},
+			},
+			Tenant:   "tenant-a",
+			Expected: preFilterDrop,
+		},
+		{
+			Desc:     "force kept",
+			Cfg:      config.PreFilterCfg{ForceKeep: []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"tenant-a"}}}},
+			Tenant:   "tenant-a",
+			Expected: preFilterKeep,
+		},
+		{
+			Desc: "force keep takes precedence",
+			Cfg: config.PreFilterCfg{
+				ForceKeep: []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"tenant-a"}}},
+				Deny:      []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"tenant-a"}}},
+			},
+			Tenant:   "tenant-a",
+			Expected: preFilterKeep,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			pf, err := newPreFilter(&c.Cfg)
+			require.NoError(t, err)
+			assert.Equal(t, c.Expected, pf.decide(tenantTraces(pdata.NewTraceID([16]byte{1}), c.Tenant).ResourceSpans().At(0).Resource()))
+		})
+	}
+}
+
+func TestPreFilterValidation(t *testing.T) {
+	_, err := newPreFilter(&config.PreFilterCfg{Deny: []config.ResourceAttributeCfg{{Values: []string{"tenant-a"}}}})
+	assert.Error(t, err)
+
+	_, err = newPreFilter(&config.PreFilterCfg{Allow: []config.ResourceAttributeCfg{{Key: "tenant.id"}}})
+	assert.Error(t, err)
+
+	_, err = newPreFilter(&config.PreFilterCfg{ForceKeep: []config.ResourceAttributeCfg{{Key: "tenant.id"}}})
+	assert.Error(t, err)
+}
+
+func TestPreFilteredTracesAreNotBuffered(t *testing.T) {
+	msp := new(consumertest.TracesSink)
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+	pf, err := newPreFilter(&config.PreFilterCfg{Deny: []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"denied-tenant"}}}})
+	require.NoError(t, err)
+
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+		func(cfsp *cascadingFilterSpanProcessor) {
+			cfsp.preFilter = pf
+		},
+	)
+
+	deniedTraceID := pdata.NewTraceID([16]byte{1})
+	allowedTraceID := pdata.NewTraceID([16]byte{2})
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tenantTraces(deniedTraceID, "denied-tenant")))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tenantTraces(allowedTraceID, "allowed-tenant")))
+
+	d, ok := tsp.idToTrace.Load(traceKey(deniedTraceID.Bytes()))
+	require.True(t, ok, "denied trace should be tracked")
+	require.Empty(t, d.(*sampling.TraceData).ReceivedBatches, "denied trace should not be buffered")
+	d, ok = tsp.idToTrace.Load(traceKey(allowedTraceID.Bytes()))
+	require.True(t, ok, "allowed trace should be buffered")
+	require.Len(t, d.(*sampling.TraceData).ReceivedBatches, 1)
+
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	require.Equal(t, 1, mpe.EvaluationCount, "only the allowed trace should be evaluated")
+	require.Equal(t, 1, msp.SpansCount())
+	require.NotNil(t, findTrace(msp.AllTraces(), allowedTraceID))
+}
+
+func TestPreFilterDropsWholeTrace(t *testing.T) {
+	msp := new(consumertest.TracesSink)
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+	pf, err := newPreFilter(&config.PreFilterCfg{Deny: []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"denied-tenant"}}}})
+	require.NoError(t, err)
+
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+		func(cfsp *cascadingFilterSpanProcessor) {
+			cfsp.preFilter = pf
+		},
+	)
+
+	// The trace spans several resources, the denied one arriving after a span was already buffered and followed by
+	// another one in the same batch
+	traceID := pdata.NewTraceID([16]byte{1})
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tenantTraces(traceID, "allowed-tenant")))
+	traces := tenantTraces(traceID, "denied-tenant")
+	traces.ResourceSpans().Append(tenantTraces(traceID, "other-tenant").ResourceSpans().At(0))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), traces))
+
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	// Spans arriving after the decision follow it as well
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tenantTraces(traceID, "allowed-tenant")))
+
+	require.Equal(t, 0, mpe.EvaluationCount, "the dropped trace should not be evaluated")
+	require.Equal(t, 0, mpe.LateArrivingSpansCount)
+	require.Equal(t, 0, msp.SpansCount())
+}
+
+func TestPreFilterForceKeepsWholeTrace(t *testing.T) {
+	msp := new(consumertest.TracesSink)
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.NotSampled}
+	pf, err := newPreFilter(&config.PreFilterCfg{
+		ForceKeep: []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"kept-tenant"}}},
+		Deny:      []config.ResourceAttributeCfg{{Key: "tenant.id", Values: []string{"denied-tenant"}}},
+	})
+	require.NoError(t, err)
+
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+		func(cfsp *cascadingFilterSpanProcessor) {
+			cfsp.preFilter = pf
+		},
+	)
+
+	traceID := pdata.NewTraceID([16]byte{1})
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tenantTraces(traceID, "other-tenant")))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tenantTraces(traceID, "kept-tenant")))
+
+	// The trace is forwarded right away, including the span buffered before
+	require.Equal(t, 2, msp.SpansCount())
+
+	// The denied resource arrives too late to drop the whole trace, so just its spans are dropped
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tenantTraces(traceID, "denied-tenant")))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tenantTraces(traceID, "other-tenant")))
+
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	require.Equal(t, 0, mpe.EvaluationCount, "the force kept trace should not be evaluated")
+	require.Equal(t, 3, msp.SpansCount())
+}
+
+func tenantTraces(traceID pdata.TraceID, tenant string) pdata.Traces {
+	traces := simpleTracesWithID(traceID)
+	traces.ResourceSpans().At(0).Resource().Attributes().UpsertString("tenant.id", tenant)
+	return traces
+}
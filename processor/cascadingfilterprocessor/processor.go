@@ -16,7 +16,12 @@ package cascadingfilterprocessor
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
 	"runtime"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -46,6 +51,37 @@ type Policy struct {
 	ctx context.Context
 	// probabilisticFilter determines whether `sampling.probability` field must be calculated and added
 	probabilisticFilter bool
+	// reservedSpansPerSecond is the part of the global limit which can be used only by this policy
+	reservedSpansPerSecond int64
+	// reservedSpansInCurrentSecond tracks how much of the reservation was used in the current second
+	reservedSpansInCurrentSecond int64
+	// priority determines the order in which "SecondChance" traces of the policy are given the remaining budget
+	priority int
+	// keepRate (optional) tracks the part of the matched traces which were sampled within the recent window
+	keepRate *keepRateWindow
+	// rateLimitedCount is the last seen number of matching traces exceeding the policy rate limit
+	rateLimitedCount int64
+	// rateLimitedSpanCount is the last seen number of spans of such traces
+	rateLimitedSpanCount int64
+
+	// Counters of provisional decisions accumulated since start, they are updated atomically
+	evaluatedCount, sampledCount, notSampledCount, secondChanceCount, dropCount, evaluationErrorCount int64
+}
+
+// PolicyStat describes the provisional decisions made by a policy since the processor started.
+type PolicyStat struct {
+	// Evaluated is the number of traces the policy was evaluated for.
+	Evaluated int64
+	// Sampled is the number of traces selected by the policy.
+	Sampled int64
+	// NotSampled is the number of traces rejected by the policy.
+	NotSampled int64
+	// SecondChance is the number of traces left for selection if the global limit is not exceeded.
+	SecondChance int64
+	// Drop is the number of traces rejected by the policy regardless of other policies.
+	Drop int64
+	// Errors is the number of evaluations which resulted in an unexpected decision.
+	Errors int64
 }
 
 // traceKey is defined since sync.Map requires a comparable type, isolating it on its own
@@ -66,17 +102,74 @@ type cascadingFilterSpanProcessor struct {
 	decisionBatcher idbatcher.Batcher
 	deleteChan      chan traceKey
 	numTracesOnMap  uint64
-
+	// maxSpansPerTrace limits the number of spans kept for a trace, 0 means no limit
+	maxSpansPerTrace int64
+	// policyEvaluationConcurrency limits the number of policies evaluated concurrently, 1 or less means sequentially
+	policyEvaluationConcurrency int
+
+	// errorDecisionBatcher (optional) holds traces with error spans, for which the decision is made sooner
+	errorDecisionBatcher idbatcher.Batcher
+	// decisionLock is held while the decisions for a batch are made
+	decisionLock sync.Mutex
+	// flushOnShutdown makes the final decision for all pending traces on shutdown
+	flushOnShutdown bool
+
+	// rateLock guards the global rate state, as it's also updated when traces are fast-tracked
+	rateLock             sync.Mutex
 	currentSecond        int64
 	maxSpansPerSecond    int64
 	spansInCurrentSecond int64
+	// spanBudget (optional) replaces the per second global limit with the budget for a longer window
+	spanBudget *spanBudget
+
+	// probabilisticFilterAdvisory determines if traces selected by both the probabilistic filter and a policy
+	// are attributed to the policy
+	probabilisticFilterAdvisory bool
+
+	emitPolicySamplingProbability bool
+	emitDecisionTime              bool
+	decisionLog                   *decisionLog
+	droppedSample                 *droppedSample
+	// preFilter (optional) drops or keeps whole traces by the resource attributes of their spans, before they are
+	// buffered
+	preFilter *preFilter
+	// requiredSpanAttributes (optional) are the span attributes without any of which the spans are dropped
+	requiredSpanAttributes []string
+	// probabilityAttributeKey (optional) overrides the attribute holding the sampling probability
+	probabilityAttributeKey string
+	// fastTrack (optional) selects traces as soon as their spans arrive
+	fastTrack sampling.PolicyEvaluator
+
+	// Counters of final decisions accumulated since start, they are updated atomically
+	sampledTracesCount, notSampledTracesCount int64
+
+	// metricsExporterName (optional) is the name of the exporter receiving the sampling statistics,
+	// it's resolved into metricsConsumer on start
+	metricsExporterName string
+	metricsConsumer     consumer.MetricsConsumer
+	metricsTicker       tTicker
+	metricsEmitInterval time.Duration
+	metricsStartTime    pdata.TimestampUnixNano
+}
+
+// evaluatedTrace keeps the trace evaluated during a tick along with its provisional decision
+// and the policy which selected it (if any).
+type evaluatedTrace struct {
+	id                  pdata.TraceID
+	trace               *sampling.TraceData
+	provisionalDecision sampling.Decision
+	matchingPolicy      *Policy
 }
 
 const (
 	probabilisticFilterPolicyName = "probabilistic_filter"
 	probabilisticRuleVale         = "probabilistic"
 	filteredRuleValue             = "filtered"
+	droppedSampleRuleValue        = "dropped-sample"
 	AttributeSamplingRule         = "sampling.rule"
+	AttributeDecisionTime         = "sampling.decision_time"
+
+	policyTickInterval = time.Second
 )
 
 // newTraceProcessor returns a processor.TraceProcessor that will perform Cascading Filter according to the given
@@ -99,13 +192,27 @@ func newCascadingFilterSpanProcessor(logger *zap.Logger, nextConsumer consumer.T
 	ctx := context.Background()
 	var policies []*Policy
 
+	if cfg.SpansPerWindow < 0 {
+		return nil, errors.New("spans per window must not be negative")
+	}
+	budgetWindow := cfg.BudgetWindow
+	if budgetWindow <= 0 {
+		budgetWindow = defaultBudgetWindow
+	}
+	// The probabilistic filter is sized by the average rate of the window budget when it replaces the global limit
+	globalSpansPerSecond := float64(cfg.SpansPerSecond)
+	if cfg.SpansPerWindow > 0 {
+		globalSpansPerSecond = float64(cfg.SpansPerWindow) / budgetWindow.Seconds()
+	}
+
 	// This must be always first as it must select traces independently of other policies
 	if cfg.ProbabilisticFilteringRatio != nil && *cfg.ProbabilisticFilteringRatio > 0.0 {
 		policyCtx, err := tag.New(ctx, tag.Upsert(tagPolicyKey, probabilisticFilterPolicyName))
 		if err != nil {
 			return nil, err
 		}
-		eval, err := getProbabilisticFilterEvaluator(logger, int64(float32(cfg.SpansPerSecond)**cfg.ProbabilisticFilteringRatio))
+		eval, err := getProbabilisticFilterEvaluator(logger, int64(globalSpansPerSecond*float64(*cfg.ProbabilisticFilteringRatio)),
+			cfg.ProbabilisticFilteringSizeBias, samplingProbabilityKey(cfg.ProbabilityAttributeKey))
 		if err != nil {
 			return nil, err
 		}
@@ -115,11 +222,17 @@ func newCascadingFilterSpanProcessor(logger *zap.Logger, nextConsumer consumer.T
 			ctx:                 policyCtx,
 			probabilisticFilter: true,
 		}
+		trackKeepRate(policy, cfg.EffectiveRateWindow)
 		policies = append(policies, policy)
 	}
 
+	reservedBudgetRatio := 0.0
 	for i := range cfg.PolicyCfgs {
 		policyCfg := &cfg.PolicyCfgs[i]
+		if policyCfg.ReservedBudgetRatio < 0 || policyCfg.ReservedBudgetRatio > 1 {
+			return nil, fmt.Errorf("reserved budget ratio of policy %s must be within [0, 1]", policyCfg.Name)
+		}
+		reservedBudgetRatio += policyCfg.ReservedBudgetRatio
 		policyCtx, err := tag.New(ctx, tag.Upsert(tagPolicyKey, policyCfg.Name))
 		if err != nil {
 			return nil, err
@@ -129,69 +242,280 @@ func newCascadingFilterSpanProcessor(logger *zap.Logger, nextConsumer consumer.T
 			return nil, err
 		}
 		policy := &Policy{
-			Name:                policyCfg.Name,
-			Evaluator:           eval,
-			ctx:                 policyCtx,
-			probabilisticFilter: false,
+			Name:                   policyCfg.Name,
+			Evaluator:              eval,
+			ctx:                    policyCtx,
+			probabilisticFilter:    false,
+			reservedSpansPerSecond: int64(float64(cfg.SpansPerSecond) * policyCfg.ReservedBudgetRatio),
+			priority:               policyCfg.Priority,
 		}
+		trackKeepRate(policy, cfg.EffectiveRateWindow)
 		policies = append(policies, policy)
 	}
+	if reservedBudgetRatio > 1 {
+		return nil, errors.New("reserved budget ratios of all policies must not exceed 1 in total")
+	}
+	// Both the keep rates and the sampling probability of policies account the traces exceeding the policy limits
+	if cfg.EffectiveRateWindow > 0 || cfg.EmitPolicySamplingProbability {
+		enableRateLimitReporting(policies)
+	}
+	for _, warning := range policyInteractionWarnings(cfg) {
+		logger.Warn(warning)
+	}
+	if cfg.SpansPerWindow > 0 && reservedBudgetRatio > 0 {
+		return nil, errors.New("reserved budget ratios are not supported along with spans per window")
+	}
 
 	cfsp := &cascadingFilterSpanProcessor{
 		ctx:               ctx,
 		nextConsumer:      nextConsumer,
 		maxNumTraces:      cfg.NumTraces,
+		maxSpansPerTrace:  cfg.MaxSpansPerTrace,
 		maxSpansPerSecond: cfg.SpansPerSecond,
 		logger:            logger,
 		decisionBatcher:   inBatcher,
 		policies:          policies,
+
+		policyEvaluationConcurrency: cfg.PolicyEvaluationConcurrency,
+
+		emitPolicySamplingProbability: cfg.EmitPolicySamplingProbability,
+		emitDecisionTime:              cfg.EmitDecisionTime,
+		probabilisticFilterAdvisory:   cfg.ProbabilisticFilteringAdvisory,
+		probabilityAttributeKey:       cfg.ProbabilityAttributeKey,
+		requiredSpanAttributes:        cfg.RequiredSpanAttributes,
+		flushOnShutdown:               cfg.FlushOnShutdown,
+
+		metricsExporterName: cfg.MetricsExporter,
+		metricsEmitInterval: cfg.MetricsEmitInterval,
+	}
+
+	if cfsp.metricsEmitInterval <= 0 {
+		cfsp.metricsEmitInterval = defaultMetricsEmitInterval
+	}
+
+	if cfg.SpansPerWindow > 0 {
+		cfsp.spanBudget = newSpanBudget(cfg.SpansPerWindow, budgetWindow, time.Now())
+	}
+
+	if cfg.PolicyEvaluationConcurrency < 0 {
+		return nil, errors.New("policy evaluation concurrency must not be negative")
+	}
+
+	if cfg.ErrorTraceDecisionWait > 0 {
+		if cfg.ErrorTraceDecisionWait >= cfg.DecisionWait {
+			return nil, errors.New("error trace decision wait must be shorter than decision wait")
+		}
+		if cfg.ErrorTraceDecisionWait%time.Second != 0 {
+			// The decisions are made every second, so the wait is the number of decision batches
+			return nil, fmt.Errorf("error trace decision wait must be a whole number of seconds, got %v", cfg.ErrorTraceDecisionWait)
+		}
+		numErrorDecisionBatches := uint64(cfg.ErrorTraceDecisionWait.Seconds())
+		cfsp.errorDecisionBatcher, err = idbatcher.New(numErrorDecisionBatches, cfg.ExpectedNewTracesPerSec, uint64(2*runtime.NumCPU()))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.DecisionLog != nil {
+		cfsp.decisionLog, err = newDecisionLog(cfg.DecisionLog)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.DroppedSample != nil {
+		cfsp.droppedSample, err = newDroppedSample(cfg.DroppedSample)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	cfsp.policyTicker = &policyTicker{onTick: cfsp.samplingPolicyOnTick}
+	if cfg.PreFilter != nil {
+		cfsp.preFilter, err = newPreFilter(cfg.PreFilter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.FastTrack != nil {
+		cfsp.fastTrack, err = getPolicyEvaluator(logger, cfg.FastTrack)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.TickJitter < 0 || cfg.TickJitter >= policyTickInterval {
+		return nil, fmt.Errorf("tick jitter must be within [0, %v)", policyTickInterval)
+	}
+
+	cfsp.policyTicker = &policyTicker{onTick: cfsp.samplingPolicyOnTick, jitter: cfg.TickJitter}
+	cfsp.metricsTicker = &policyTicker{onTick: cfsp.emitSamplingMetrics}
 	cfsp.deleteChan = make(chan traceKey, cfg.NumTraces)
 
 	return cfsp, nil
 }
 
+// trackKeepRate makes the effective keep rate of the policy tracked over the window, unless it's not set
+func trackKeepRate(policy *Policy, window time.Duration) {
+	if window > 0 {
+		policy.keepRate = newKeepRateWindow(window)
+	}
+}
+
+// enableRateLimitReporting asks the evaluators to report the matching traces exceeding their rate limits, which makes
+// them check the criteria of such traces as well
+func enableRateLimitReporting(policies []*Policy) {
+	for _, policy := range policies {
+		if reporter, ok := policy.Evaluator.(sampling.RateLimitReporter); ok {
+			reporter.EnableRateLimitReporting()
+		}
+	}
+}
+
+// rateLimitedSpans returns the number of spans of the matching traces which exceeded the rate limit of each policy
+// since the previous call
+func (cfsp *cascadingFilterSpanProcessor) rateLimitedSpans() map[*Policy]int64 {
+	spans := make(map[*Policy]int64)
+	for _, policy := range cfsp.policies {
+		if reporter, ok := policy.Evaluator.(sampling.RateLimitReporter); ok {
+			count := reporter.RateLimitedSpanCount()
+			spans[policy] = count - policy.rateLimitedSpanCount
+			policy.rateLimitedSpanCount = count
+		}
+	}
+	return spans
+}
+
 func getPolicyEvaluator(logger *zap.Logger, cfg *config.PolicyCfg) (sampling.PolicyEvaluator, error) {
 	return sampling.NewFilter(logger, cfg)
 }
 
-func getProbabilisticFilterEvaluator(logger *zap.Logger, maxSpanRate int64) (sampling.PolicyEvaluator, error) {
-	return sampling.NewProbabilisticFilter(logger, maxSpanRate)
+func getProbabilisticFilterEvaluator(logger *zap.Logger, maxSpanRate int64, sizeBias float64, probabilityKey string) (sampling.PolicyEvaluator, error) {
+	return sampling.NewProbabilisticFilter(logger, maxSpanRate, sizeBias, probabilityKey)
 }
 
 type policyMetrics struct {
-	idNotFoundOnMapCount, evaluateErrorCount, decisionSampled, decisionNotSampled int64
+	idNotFoundOnMapCount, decisionSampled, decisionNotSampled, spansOverRateLimit int64
+}
+
+// updateRate checks if the trace selected by the policy fits the global limit and accounts its spans if so.
+// The trace might use the budget reserved for its policy and the budget not reserved for any policy. The policy
+// is nil for traces which are not entitled to any reservation.
+func (cfsp *cascadingFilterSpanProcessor) updateRate(currSecond int64, numSpans int64, policy *Policy) sampling.Decision {
+	cfsp.rateLock.Lock()
+	defer cfsp.rateLock.Unlock()
+
+	if cfsp.spanBudget != nil {
+		if cfsp.spanBudget.take(numSpans, time.Now()) {
+			return sampling.Sampled
+		}
+		return sampling.NotSampled
+	}
+
+	cfsp.resetRateIfNewSecond(currSecond)
+
+	if numSpans > cfsp.maxSpansPerSecond-cfsp.spansInCurrentSecond-cfsp.reservedSpansLeft(policy) {
+		return sampling.NotSampled
+	}
+
+	cfsp.spansInCurrentSecond += numSpans
+	if policy != nil {
+		fromReservation := policy.reservedSpansPerSecond - policy.reservedSpansInCurrentSecond
+		if fromReservation > numSpans {
+			fromReservation = numSpans
+		}
+		policy.reservedSpansInCurrentSecond += fromReservation
+	}
+	return sampling.Sampled
 }
 
-func (cfsp *cascadingFilterSpanProcessor) updateRate(currSecond int64, numSpans int64) sampling.Decision {
+// resetRateIfNewSecond clears the rate state when a new second begins. The rate lock must be held when calling it
+func (cfsp *cascadingFilterSpanProcessor) resetRateIfNewSecond(currSecond int64) {
 	if cfsp.currentSecond != currSecond {
 		cfsp.currentSecond = currSecond
 		cfsp.spansInCurrentSecond = 0
+		for _, policy := range cfsp.policies {
+			policy.reservedSpansInCurrentSecond = 0
+		}
 	}
+}
 
-	spansInSecondIfSampled := cfsp.spansInCurrentSecond + numSpans
-	if spansInSecondIfSampled <= cfsp.maxSpansPerSecond {
-		cfsp.spansInCurrentSecond = spansInSecondIfSampled
-		return sampling.Sampled
+// reservedSpansLeft returns how much of the budget is still reserved in the current second for the policies
+// other than the given one
+func (cfsp *cascadingFilterSpanProcessor) reservedSpansLeft(except *Policy) int64 {
+	reserved := int64(0)
+	for _, policy := range cfsp.policies {
+		if policy != except && policy.reservedSpansInCurrentSecond < policy.reservedSpansPerSecond {
+			reserved += policy.reservedSpansPerSecond - policy.reservedSpansInCurrentSecond
+		}
 	}
+	return reserved
+}
+
+// remainingSpansInSecond returns how many spans still fit the global limit in the given second,
+// not counting the budget reserved for policies
+func (cfsp *cascadingFilterSpanProcessor) remainingSpansInSecond(currSecond int64) int64 {
+	cfsp.rateLock.Lock()
+	defer cfsp.rateLock.Unlock()
 
-	return sampling.NotSampled
+	if cfsp.spanBudget != nil {
+		return cfsp.spanBudget.remaining(time.Now())
+	}
+
+	cfsp.resetRateIfNewSecond(currSecond)
+	return cfsp.maxSpansPerSecond - cfsp.spansInCurrentSecond - cfsp.reservedSpansLeft(nil)
 }
 
-func (cfsp *cascadingFilterSpanProcessor) samplingPolicyOnTick() {
-	metrics := policyMetrics{}
+// remainingGlobalSpans returns how many spans still fit the global limit. Unlike remainingSpansInSecond, the budget
+// reserved for policies is included, as it's still a part of the global limit
+func (cfsp *cascadingFilterSpanProcessor) remainingGlobalSpans(currSecond int64) int64 {
+	cfsp.rateLock.Lock()
+	defer cfsp.rateLock.Unlock()
+
+	if cfsp.spanBudget != nil {
+		return cfsp.spanBudget.remaining(time.Now())
+	}
 
+	cfsp.resetRateIfNewSecond(currSecond)
+	return cfsp.maxSpansPerSecond - cfsp.spansInCurrentSecond
+}
+
+func (cfsp *cascadingFilterSpanProcessor) samplingPolicyOnTick() {
 	startTime := time.Now()
 	batch, _ := cfsp.decisionBatcher.CloseCurrentAndTakeFirstBatch()
-	batchLen := len(batch)
+	if cfsp.errorDecisionBatcher != nil {
+		errorBatch, _ := cfsp.errorDecisionBatcher.CloseCurrentAndTakeFirstBatch()
+		batch = append(errorBatch, batch...)
+	}
 	cfsp.logger.Debug("Sampling Policy Evaluation ticked")
 
+	cfsp.decideBatch(startTime, batch)
+}
+
+// decideBatch makes the final decisions for the traces of the batch and forwards the sampled ones
+func (cfsp *cascadingFilterSpanProcessor) decideBatch(startTime time.Time, batch idbatcher.Batch) {
+	// The batches are decided one at a time, so the final flush does not overlap with a regular tick
+	cfsp.decisionLock.Lock()
+	defer cfsp.decisionLock.Unlock()
+
+	metrics := policyMetrics{}
+	batchLen := len(batch)
+
 	currSecond := time.Now().Unix()
 
 	totalSpans := int64(0)
 	selectedByProbabilisticFilterSpans := int64(0)
+	secondChanceSpans := int64(0)
+
+	// Spans of traces selected by each policy and of those which eventually fit the global limit
+	policySelectedSpans := make(map[*Policy]int64)
+	policySampledSpans := make(map[*Policy]int64)
+
+	// Traces matched by each policy and of those which were eventually sampled
+	policyMatchedTraces := make(map[*Policy]int64)
+	policyKeptTraces := make(map[*Policy]int64)
+
+	evaluatedTraces := make([]evaluatedTrace, 0, batchLen)
 
 	// The first run applies decisions to batches, executing each policy separately
 	for _, id := range batch {
@@ -201,22 +525,40 @@ func (cfsp *cascadingFilterSpanProcessor) samplingPolicyOnTick() {
 			continue
 		}
 		trace := d.(*sampling.TraceData)
+		trace.Lock()
+		decided := trace.FinalDecision != sampling.Unspecified
+		trace.Unlock()
+		if decided {
+			// The decision was already made, which happens for traces with expedited decision or fast-tracked ones
+			continue
+		}
 		trace.DecisionTime = time.Now()
+		stats.Record(cfsp.ctx, statTraceAgeAtDecisionSec.M(int64(trace.DecisionTime.Sub(trace.ArrivalTime)/time.Second)))
 		totalSpans += trace.SpanCount
 
-		provisionalDecision, _ := cfsp.makeProvisionalDecision(id, trace)
+		provisionalDecision, matchingPolicy := cfsp.makeProvisionalDecision(id, trace)
+		evaluatedTraces = append(evaluatedTraces, evaluatedTrace{
+			id:                  id,
+			trace:               trace,
+			provisionalDecision: provisionalDecision,
+			matchingPolicy:      matchingPolicy,
+		})
+
 		if provisionalDecision == sampling.Sampled {
-			trace.FinalDecision = cfsp.updateRate(currSecond, trace.SpanCount)
+			setFinalDecision(trace, cfsp.updateRate(currSecond, trace.SpanCount, matchingPolicy))
+			policySelectedSpans[matchingPolicy] += trace.SpanCount
 			if trace.FinalDecision == sampling.Sampled {
 				if trace.SelectedByProbabilisticFilter {
 					selectedByProbabilisticFilterSpans += trace.SpanCount
 				}
+				policySampledSpans[matchingPolicy] += trace.SpanCount
 				_ = stats.RecordWithTags(
 					cfsp.ctx,
 					[]tag.Mutator{tag.Insert(tagCascadingFilterDecisionKey, statusSampled)},
 					statCascadingFilterDecision.M(int64(1)),
 				)
 			} else {
+				metrics.spansOverRateLimit += trace.SpanCount
 				_ = stats.RecordWithTags(
 					cfsp.ctx,
 					[]tag.Mutator{tag.Insert(tagCascadingFilterDecisionKey, statusExceededKey)},
@@ -224,9 +566,11 @@ func (cfsp *cascadingFilterSpanProcessor) samplingPolicyOnTick() {
 				)
 			}
 		} else if provisionalDecision == sampling.SecondChance {
-			trace.FinalDecision = sampling.SecondChance
+			setFinalDecision(trace, sampling.SecondChance)
+			secondChanceSpans += trace.SpanCount
 		} else {
-			trace.FinalDecision = provisionalDecision
+			// This includes traces for which no policy made a valid decision
+			setFinalDecision(trace, sampling.NotSampled)
 			_ = stats.RecordWithTags(
 				cfsp.ctx,
 				[]tag.Mutator{tag.Insert(tagCascadingFilterDecisionKey, statusNotSampled)},
@@ -235,15 +579,40 @@ func (cfsp *cascadingFilterSpanProcessor) samplingPolicyOnTick() {
 		}
 	}
 
+	// Spans of the traces matched by each policy, which exceeded its own limit rather than the global one
+	var policyRateLimitedSpans map[*Policy]int64
+	if cfsp.emitPolicySamplingProbability {
+		policyRateLimitedSpans = cfsp.rateLimitedSpans()
+	}
+
+	// "SecondChance" traces share whatever is left of the global limit after the first run
+	secondChanceRatio := 1.0
+	if remaining := cfsp.remainingSpansInSecond(currSecond); secondChanceSpans > remaining {
+		if remaining < 0 {
+			remaining = 0
+		}
+		secondChanceRatio = float64(remaining) / float64(secondChanceSpans)
+	}
+
+	// "SecondChance" traces of policies with higher priority are given the remaining budget first
+	sortBySecondChancePriority(evaluatedTraces)
+
 	// The second run executes the decisions and makes "SecondChance" decisions in the meantime
-	for _, id := range batch {
-		d, ok := cfsp.idToTrace.Load(traceKey(id.Bytes()))
-		if !ok {
-			continue
+	for _, et := range evaluatedTraces {
+		trace := et.trace
+
+		// The final decision is made and the batches are removed at once, so spans arriving in the meantime
+		// are either buffered and released here or handled as late arriving
+		trace.Lock()
+		secondChance := trace.FinalDecision == sampling.SecondChance
+		if secondChance {
+			trace.FinalDecision = cfsp.updateRate(currSecond, trace.SpanCount, nil)
 		}
-		trace := d.(*sampling.TraceData)
-		if trace.FinalDecision == sampling.SecondChance {
-			trace.FinalDecision = cfsp.updateRate(currSecond, trace.SpanCount)
+		traceBatches := trace.ReceivedBatches
+		trace.ReceivedBatches = nil
+		trace.Unlock()
+
+		if secondChance {
 			if trace.FinalDecision == sampling.Sampled {
 				_ = stats.RecordWithTags(
 					cfsp.ctx,
@@ -251,6 +620,7 @@ func (cfsp *cascadingFilterSpanProcessor) samplingPolicyOnTick() {
 					statCascadingFilterDecision.M(int64(1)),
 				)
 			} else {
+				metrics.spansOverRateLimit += trace.SpanCount
 				_ = stats.RecordWithTags(
 					cfsp.ctx,
 					[]tag.Mutator{tag.Insert(tagCascadingFilterDecisionKey, statusSecondChanceExceeded)},
@@ -259,51 +629,153 @@ func (cfsp *cascadingFilterSpanProcessor) samplingPolicyOnTick() {
 			}
 		}
 
-		// Sampled or not, remove the batches
-		trace.Lock()
-		traceBatches := trace.ReceivedBatches
-		trace.ReceivedBatches = nil
-		trace.Unlock()
+		for i, policy := range cfsp.policies {
+			if decision := trace.Decisions[i]; decision == sampling.Sampled || decision == sampling.SecondChance {
+				policyMatchedTraces[policy]++
+				if trace.FinalDecision == sampling.Sampled {
+					policyKeptTraces[policy]++
+				}
+			}
+		}
+
+		if cfsp.decisionLog != nil {
+			if err := cfsp.decisionLog.record(et, traceBatches); err != nil {
+				cfsp.logger.Warn("Error writing to decision log", zap.Error(err))
+			}
+		}
 
 		if trace.FinalDecision == sampling.Sampled {
 			metrics.decisionSampled++
 
 			// Combine all individual batches into a single batch so
 			// consumers may operate on the entire trace
-			allSpans := pdata.NewTraces()
-			for j := 0; j < len(traceBatches); j++ {
-				batch := traceBatches[j]
-				batch.ResourceSpans().MoveAndAppendTo(allSpans.ResourceSpans())
-			}
+			allSpans := combineBatches(traceBatches)
 
 			if trace.SelectedByProbabilisticFilter {
-				updateProbabilisticRateTag(allSpans, selectedByProbabilisticFilterSpans, totalSpans)
+				updateProbabilisticRateTag(allSpans, cfsp.samplingProbabilityKey(), trace.UpstreamSamplingProbability,
+					selectedByProbabilisticFilterSpans, totalSpans)
 			} else {
 				updateFilteringTag(allSpans)
+				if cfsp.emitPolicySamplingProbability {
+					ratio := secondChanceRatio
+					if et.provisionalDecision == sampling.Sampled {
+						ratio = float64(policySampledSpans[et.matchingPolicy]) /
+							float64(policySelectedSpans[et.matchingPolicy]+policyRateLimitedSpans[et.matchingPolicy])
+					}
+					updateSamplingProbabilityTag(allSpans, cfsp.samplingProbabilityKey(), ratio)
+				}
+			}
+
+			if cfsp.emitDecisionTime {
+				updateDecisionTimeTag(allSpans, startTime)
 			}
 
 			_ = cfsp.nextConsumer.ConsumeTraces(cfsp.ctx, allSpans)
 		} else {
 			metrics.decisionNotSampled++
+
+			if cfsp.droppedSample != nil {
+				cfsp.droppedSample.forward(cfsp.ctx, traceBatches, cfsp.samplingProbabilityKey(), cfsp.logger)
+			}
+		}
+	}
+
+	atomic.AddInt64(&cfsp.sampledTracesCount, metrics.decisionSampled)
+	atomic.AddInt64(&cfsp.notSampledTracesCount, metrics.decisionNotSampled)
+
+	cfsp.updateKeepRates(currSecond, policyMatchedTraces, policyKeptTraces)
+
+	if cfsp.decisionLog != nil {
+		if err := cfsp.decisionLog.flush(); err != nil {
+			cfsp.logger.Warn("Error writing to decision log", zap.Error(err))
 		}
 	}
 
+	remainingSpans := cfsp.remainingGlobalSpans(currSecond)
+
 	stats.Record(cfsp.ctx,
 		statOverallDecisionLatencyus.M(int64(time.Since(startTime)/time.Microsecond)),
 		statDroppedTooEarlyCount.M(metrics.idNotFoundOnMapCount),
-		statPolicyEvaluationErrorCount.M(metrics.evaluateErrorCount),
-		statTracesOnMemoryGauge.M(int64(atomic.LoadUint64(&cfsp.numTracesOnMap))))
+		statTracesOnMemoryGauge.M(int64(atomic.LoadUint64(&cfsp.numTracesOnMap))),
+		statRemainingSpansPerSecondGauge.M(remainingSpans),
+		statSpansOverRateLimitCount.M(metrics.spansOverRateLimit))
 
 	cfsp.logger.Debug("Sampling policy evaluation completed",
 		zap.Int("batch.len", batchLen),
 		zap.Int64("sampled", metrics.decisionSampled),
 		zap.Int64("notSampled", metrics.decisionNotSampled),
 		zap.Int64("droppedPriorToEvaluation", metrics.idNotFoundOnMapCount),
-		zap.Int64("policyEvaluationErrors", metrics.evaluateErrorCount),
+		zap.Int64("spansOverRateLimit", metrics.spansOverRateLimit),
+		zap.Int64("remainingSpansPerSecond", remainingSpans),
 	)
 }
 
-func updateProbabilisticRateTag(traces pdata.Traces, probabilisticSpans int64, allSpans int64) {
+// updateKeepRates accounts the traces matched and kept by each policy in the given second and records the effective
+// keep rates. The traces matching the policy but exceeding its rate limit are counted as matched as well
+func (cfsp *cascadingFilterSpanProcessor) updateKeepRates(currSecond int64, matched map[*Policy]int64, kept map[*Policy]int64) {
+	for _, policy := range cfsp.policies {
+		if policy.keepRate == nil {
+			continue
+		}
+
+		policyMatched := matched[policy]
+		if reporter, ok := policy.Evaluator.(sampling.RateLimitReporter); ok {
+			rateLimited := reporter.RateLimitedCount()
+			policyMatched += rateLimited - policy.rateLimitedCount
+			policy.rateLimitedCount = rateLimited
+		}
+
+		policy.keepRate.add(currSecond, policyMatched, kept[policy])
+		if rate, ok := policy.keepRate.rate(currSecond); ok {
+			stats.Record(policy.ctx, statPolicyEffectiveKeepRate.M(rate))
+		}
+	}
+}
+
+// sortBySecondChancePriority orders the "SecondChance" traces by the priority of the policy which has given them,
+// keeping the order of arrival otherwise. The remaining traces (which already have final decisions) are put first
+func sortBySecondChancePriority(evaluatedTraces []evaluatedTrace) {
+	priority := func(et evaluatedTrace) int {
+		if et.provisionalDecision != sampling.SecondChance {
+			return math.MaxInt32
+		}
+		if et.matchingPolicy == nil {
+			return 0
+		}
+		return et.matchingPolicy.priority
+	}
+
+	sort.SliceStable(evaluatedTraces, func(i, j int) bool {
+		return priority(evaluatedTraces[i]) > priority(evaluatedTraces[j])
+	})
+}
+
+// combineBatches moves the spans of all batches into a single one
+func combineBatches(batches []pdata.Traces) pdata.Traces {
+	allSpans := pdata.NewTraces()
+	for j := 0; j < len(batches); j++ {
+		batch := batches[j]
+		batch.ResourceSpans().MoveAndAppendTo(allSpans.ResourceSpans())
+	}
+	return allSpans
+}
+
+// samplingProbabilityKey returns the span attribute holding the sampling probability
+func (cfsp *cascadingFilterSpanProcessor) samplingProbabilityKey() string {
+	return samplingProbabilityKey(cfsp.probabilityAttributeKey)
+}
+
+func samplingProbabilityKey(probabilityAttributeKey string) string {
+	if probabilityAttributeKey == "" {
+		return conventions.AttributeSamplingProbability
+	}
+	return probabilityAttributeKey
+}
+
+// updateProbabilisticRateTag sets the sampling probability of the probabilistic filter for each span of the trace. When
+// the upstream sampling probability of the trace is known, it's combined with the ratio and set for all spans (even
+// the ones which did not have it), otherwise the probability already set for each span is multiplied by the ratio
+func updateProbabilisticRateTag(traces pdata.Traces, probabilityKey string, upstreamProbability float64, probabilisticSpans int64, allSpans int64) {
 	ratio := float64(probabilisticSpans) / float64(allSpans)
 
 	rs := traces.ResourceSpans()
@@ -314,11 +786,10 @@ func updateProbabilisticRateTag(traces pdata.Traces, probabilisticSpans int64, a
 			spans := ils.At(j).Spans()
 			for k := 0; k < spans.Len(); k++ {
 				attrs := spans.At(k).Attributes()
-				av, found := attrs.Get(conventions.AttributeSamplingProbability)
-				if found && av.Type() == pdata.AttributeValueDOUBLE {
-					av.SetDoubleVal(av.DoubleVal() * ratio)
+				if upstreamProbability > 0 {
+					attrs.UpsertDouble(probabilityKey, upstreamProbability*ratio)
 				} else {
-					attrs.UpsertDouble(conventions.AttributeSamplingProbability, ratio)
+					updateSamplingProbability(attrs, probabilityKey, ratio)
 				}
 				attrs.UpsertString(AttributeSamplingRule, probabilisticRuleVale)
 			}
@@ -326,6 +797,31 @@ func updateProbabilisticRateTag(traces pdata.Traces, probabilisticSpans int64, a
 	}
 }
 
+// updateSamplingProbabilityTag sets the effective sampling probability for each span of the trace
+func updateSamplingProbabilityTag(traces pdata.Traces, probabilityKey string, ratio float64) {
+	rs := traces.ResourceSpans()
+
+	for i := 0; i < rs.Len(); i++ {
+		ils := rs.At(i).InstrumentationLibrarySpans()
+		for j := 0; j < ils.Len(); j++ {
+			spans := ils.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				updateSamplingProbability(spans.At(k).Attributes(), probabilityKey, ratio)
+			}
+		}
+	}
+}
+
+// updateSamplingProbability multiplies the probability already set (e.g. by head-based sampling) by the given ratio
+func updateSamplingProbability(attrs pdata.AttributeMap, probabilityKey string, ratio float64) {
+	av, found := attrs.Get(probabilityKey)
+	if found && av.Type() == pdata.AttributeValueDOUBLE {
+		av.SetDoubleVal(av.DoubleVal() * ratio)
+	} else {
+		attrs.UpsertDouble(probabilityKey, ratio)
+	}
+}
+
 func updateFilteringTag(traces pdata.Traces) {
 	rs := traces.ResourceSpans()
 
@@ -341,25 +837,49 @@ func updateFilteringTag(traces pdata.Traces) {
 	}
 }
 
+// updateDecisionTimeTag sets the time of the tick which made the decision for each resource of the trace
+func updateDecisionTimeTag(traces pdata.Traces, decisionTime time.Time) {
+	rs := traces.ResourceSpans()
+
+	for i := 0; i < rs.Len(); i++ {
+		rs.At(i).Resource().Attributes().UpsertInt(AttributeDecisionTime, decisionTime.UnixNano())
+	}
+}
+
+// makeProvisionalDecision evaluates all policies for the trace. It returns the first policy which selected the trace or,
+// in case of "SecondChance", the policy of the highest priority which has given it (the first one of them if there are
+// more). When the probabilistic filter is advisory, it's returned only if no other policy selected the trace. When any
+// policy returned "Drop", the trace is not sampled and that policy is returned.
+//
+// The decisions take precedence in the following order: "Drop", "Sampled", "SecondChance" and "NotSampled". So a trace
+// exceeding the limit of a policy (which returns "NotSampled" then) might still get the "SecondChance" of another one.
+// However, a trace selected by a policy does not get a "SecondChance" when it exceeds the global limit, as the first
+// run takes whatever is left of it anyway
 func (cfsp *cascadingFilterSpanProcessor) makeProvisionalDecision(id pdata.TraceID, trace *sampling.TraceData) (sampling.Decision, *Policy) {
 	provisionalDecision := sampling.Unspecified
 	var matchingPolicy *Policy = nil
+	var secondChancePolicy *Policy = nil
+	var advisoryPolicy *Policy = nil
+	var dropPolicy *Policy = nil
 
-	for i, policy := range cfsp.policies {
-		policyEvaluateStartTime := time.Now()
-		decision := policy.Evaluator.Evaluate(id, trace)
-		stats.Record(
-			policy.ctx,
-			statDecisionLatencyMicroSec.M(int64(time.Since(policyEvaluateStartTime)/time.Microsecond)))
+	decisions := cfsp.evaluatePolicies(id, trace)
 
+	// The decisions are combined in the order of policies, regardless of how they were evaluated
+	for i, policy := range cfsp.policies {
+		decision := decisions[i]
+		trace.Lock()
 		trace.Decisions[i] = decision
+		trace.Unlock()
+		atomic.AddInt64(&policy.evaluatedCount, 1)
 
 		switch decision {
 		case sampling.Sampled:
 			// any single policy that decides to sample will cause the decision to be sampled
 			// the nextConsumer will get the context from the first matching policy
 			provisionalDecision = sampling.Sampled
-			if matchingPolicy == nil {
+			if policy.probabilisticFilter && cfsp.probabilisticFilterAdvisory {
+				advisoryPolicy = policy
+			} else if matchingPolicy == nil {
 				matchingPolicy = policy
 			}
 
@@ -367,6 +887,8 @@ func (cfsp *cascadingFilterSpanProcessor) makeProvisionalDecision(id pdata.Trace
 				trace.SelectedByProbabilisticFilter = true
 			}
 
+			atomic.AddInt64(&policy.sampledCount, 1)
+
 			_ = stats.RecordWithTags(
 				policy.ctx,
 				[]tag.Mutator{tag.Insert(tagPolicyDecisionKey, statusSampled)},
@@ -376,6 +898,7 @@ func (cfsp *cascadingFilterSpanProcessor) makeProvisionalDecision(id pdata.Trace
 			if provisionalDecision == sampling.Unspecified {
 				provisionalDecision = sampling.NotSampled
 			}
+			atomic.AddInt64(&policy.notSampledCount, 1)
 			_ = stats.RecordWithTags(
 				policy.ctx,
 				[]tag.Mutator{tag.Insert(tagPolicyDecisionKey, statusNotSampled)},
@@ -385,23 +908,136 @@ func (cfsp *cascadingFilterSpanProcessor) makeProvisionalDecision(id pdata.Trace
 			if provisionalDecision != sampling.Sampled {
 				provisionalDecision = sampling.SecondChance
 			}
+			if secondChancePolicy == nil || policy.priority > secondChancePolicy.priority {
+				secondChancePolicy = policy
+			}
+			atomic.AddInt64(&policy.secondChanceCount, 1)
 
 			_ = stats.RecordWithTags(
 				policy.ctx,
 				[]tag.Mutator{tag.Insert(tagPolicyDecisionKey, statusSecondChance)},
 				statPolicyDecision.M(int64(1)),
 			)
+		case sampling.Drop:
+			if dropPolicy == nil {
+				dropPolicy = policy
+			}
+			atomic.AddInt64(&policy.dropCount, 1)
+
+			_ = stats.RecordWithTags(
+				policy.ctx,
+				[]tag.Mutator{tag.Insert(tagPolicyDecisionKey, statusDrop)},
+				statPolicyDecision.M(int64(1)),
+			)
+		default:
+			atomic.AddInt64(&policy.evaluationErrorCount, 1)
+			stats.Record(policy.ctx, statPolicyEvaluationErrorCount.M(int64(1)))
 		}
 	}
 
+	// "Drop" overrides the decisions of all other policies
+	if dropPolicy != nil {
+		trace.SelectedByProbabilisticFilter = false
+		return sampling.NotSampled, dropPolicy
+	}
+
+	if advisoryPolicy != nil {
+		if matchingPolicy != nil {
+			trace.SelectedByProbabilisticFilter = false
+		} else {
+			matchingPolicy = advisoryPolicy
+		}
+	}
+
+	if provisionalDecision == sampling.SecondChance {
+		return provisionalDecision, secondChancePolicy
+	}
 	return provisionalDecision, matchingPolicy
 }
 
+// evaluatePolicies returns the decision of each policy for the trace. The policies are evaluated concurrently
+// (at most policyEvaluationConcurrency at once) when configured, sequentially otherwise
+func (cfsp *cascadingFilterSpanProcessor) evaluatePolicies(id pdata.TraceID, trace *sampling.TraceData) []sampling.Decision {
+	decisions := make([]sampling.Decision, len(cfsp.policies))
+
+	if cfsp.policyEvaluationConcurrency <= 1 || len(cfsp.policies) <= 1 {
+		for i, policy := range cfsp.policies {
+			decisions[i] = evaluatePolicy(policy, id, trace)
+		}
+		return decisions
+	}
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, cfsp.policyEvaluationConcurrency)
+	for i, policy := range cfsp.policies {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(i int, policy *Policy) {
+			defer wg.Done()
+			decisions[i] = evaluatePolicy(policy, id, trace)
+			<-workers
+		}(i, policy)
+	}
+	wg.Wait()
+
+	return decisions
+}
+
+func evaluatePolicy(policy *Policy, id pdata.TraceID, trace *sampling.TraceData) sampling.Decision {
+	policyEvaluateStartTime := time.Now()
+	decision := policy.Evaluator.Evaluate(id, trace)
+	stats.Record(
+		policy.ctx,
+		statDecisionLatencyMicroSec.M(int64(time.Since(policyEvaluateStartTime)/time.Microsecond)))
+	return decision
+}
+
+// setFinalDecision updates the final decision of the trace under its lock, as it's checked when new spans arrive
+func setFinalDecision(trace *sampling.TraceData, decision sampling.Decision) {
+	trace.Lock()
+	trace.FinalDecision = decision
+	trace.Unlock()
+}
+
+// PolicyEffectiveKeepRates returns the part of the traces matched by each of the policies which were sampled within
+// the recent window, keyed by the policy name. The policies which matched no traces within the window are omitted.
+// It is safe to call it concurrently with the processing of traces.
+func (cfsp *cascadingFilterSpanProcessor) PolicyEffectiveKeepRates() map[string]float64 {
+	currSecond := time.Now().Unix()
+	keepRates := make(map[string]float64, len(cfsp.policies))
+	for _, policy := range cfsp.policies {
+		if policy.keepRate == nil {
+			continue
+		}
+		if rate, ok := policy.keepRate.rate(currSecond); ok {
+			keepRates[policy.Name] = rate
+		}
+	}
+	return keepRates
+}
+
+// PolicyStats returns the provisional decisions counters accumulated by each of the policies since start,
+// keyed by the policy name. It is safe to call it concurrently with the processing of traces.
+func (cfsp *cascadingFilterSpanProcessor) PolicyStats() map[string]PolicyStat {
+	policyStats := make(map[string]PolicyStat, len(cfsp.policies))
+	for _, policy := range cfsp.policies {
+		policyStats[policy.Name] = PolicyStat{
+			Evaluated:    atomic.LoadInt64(&policy.evaluatedCount),
+			Sampled:      atomic.LoadInt64(&policy.sampledCount),
+			NotSampled:   atomic.LoadInt64(&policy.notSampledCount),
+			SecondChance: atomic.LoadInt64(&policy.secondChanceCount),
+			Drop:         atomic.LoadInt64(&policy.dropCount),
+			Errors:       atomic.LoadInt64(&policy.evaluationErrorCount),
+		}
+	}
+	return policyStats
+}
+
 // ConsumeTraceData is required by the SpanProcessor interface.
 func (cfsp *cascadingFilterSpanProcessor) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
 	cfsp.start.Do(func() {
 		cfsp.logger.Info("First trace data arrived, starting cascading_filter timers")
-		cfsp.policyTicker.Start(1 * time.Second)
+		cfsp.policyTicker.Start(policyTickInterval)
 	})
 	resourceSpans := td.ResourceSpans()
 	for i := 0; i < resourceSpans.Len(); i++ {
@@ -411,14 +1047,21 @@ func (cfsp *cascadingFilterSpanProcessor) ConsumeTraces(ctx context.Context, td
 	return nil
 }
 
-func (cfsp *cascadingFilterSpanProcessor) groupSpansByTraceKey(resourceSpans pdata.ResourceSpans) map[traceKey][]*pdata.Span {
+// groupSpansByTraceKey groups the spans by their traces. It also returns the number of spans which were skipped
+// as they missed any of the required attributes
+func (cfsp *cascadingFilterSpanProcessor) groupSpansByTraceKey(resourceSpans pdata.ResourceSpans) (map[traceKey][]*pdata.Span, int64) {
 	idToSpans := make(map[traceKey][]*pdata.Span)
+	var spansMissingRequiredAttrs int64
 	ilss := resourceSpans.InstrumentationLibrarySpans()
 	for j := 0; j < ilss.Len(); j++ {
 		ils := ilss.At(j)
 		spansLen := ils.Spans().Len()
 		for k := 0; k < spansLen; k++ {
 			span := ils.Spans().At(k)
+			if !cfsp.hasRequiredAttributes(span) {
+				spansMissingRequiredAttrs++
+				continue
+			}
 			tk := traceKey(span.TraceID().Bytes())
 			if len(tk) != 16 {
 				cfsp.logger.Warn("Span without valid TraceId")
@@ -426,13 +1069,30 @@ func (cfsp *cascadingFilterSpanProcessor) groupSpansByTraceKey(resourceSpans pda
 			idToSpans[tk] = append(idToSpans[tk], &span)
 		}
 	}
-	return idToSpans
+	return idToSpans, spansMissingRequiredAttrs
+}
+
+func (cfsp *cascadingFilterSpanProcessor) hasRequiredAttributes(span pdata.Span) bool {
+	attrs := span.Attributes()
+	for _, key := range cfsp.requiredSpanAttributes {
+		if _, found := attrs.Get(key); !found {
+			return false
+		}
+	}
+	return true
 }
 
 func (cfsp *cascadingFilterSpanProcessor) processTraces(resourceSpans pdata.ResourceSpans) {
+	preFilterDecision := preFilterNoMatch
+	if cfsp.preFilter != nil {
+		preFilterDecision = cfsp.preFilter.decide(resourceSpans.Resource())
+	}
+
 	// Group spans per their traceId to minimize contention on idToTrace
-	idToSpans := cfsp.groupSpansByTraceKey(resourceSpans)
+	idToSpans, spansMissingRequiredAttrs := cfsp.groupSpansByTraceKey(resourceSpans)
 	var newTraceIDs int64
+	var spansOverTraceLimit int64
+	var spansDroppedByPreFilter int64
 	for id, spans := range idToSpans {
 		lenSpans := int64(len(spans))
 		lenPolicies := len(cfsp.policies)
@@ -443,15 +1103,11 @@ func (cfsp *cascadingFilterSpanProcessor) processTraces(resourceSpans pdata.Reso
 		initialTraceData := &sampling.TraceData{
 			Decisions:   initialDecisions,
 			ArrivalTime: time.Now(),
-			SpanCount:   lenSpans,
 		}
 		d, loaded := cfsp.idToTrace.LoadOrStore(id, initialTraceData)
 
 		actualData := d.(*sampling.TraceData)
-		if loaded {
-			// PMM: why actualData is not updated with new trace?
-			atomic.AddInt64(&actualData.SpanCount, lenSpans)
-		} else {
+		if !loaded {
 			newTraceIDs++
 			cfsp.decisionBatcher.AddToCurrentBatch(pdata.NewTraceID(id))
 			atomic.AddUint64(&cfsp.numTracesOnMap, 1)
@@ -470,56 +1126,178 @@ func (cfsp *cascadingFilterSpanProcessor) processTraces(resourceSpans pdata.Reso
 			}
 		}
 
-		for i, policy := range cfsp.policies {
-			var traceTd pdata.Traces
-			actualData.Lock()
-			actualDecision := actualData.Decisions[i]
-			// If decision is pending, we want to add the new spans still under the lock, so the decision doesn't happen
-			// in between the transition from pending.
-			if actualDecision == sampling.Pending {
-				// Add the spans to the trace, but only once for all policy, otherwise same spans will
-				// be duplicated in the final trace.
-				traceTd = prepareTraceBatch(resourceSpans, spans)
-				actualData.ReceivedBatches = append(actualData.ReceivedBatches, traceTd)
-				actualData.Unlock()
-				break
+		actualData.Lock()
+		if preFilterDecision == preFilterDrop && actualData.FinalDecision == sampling.Unspecified {
+			// The whole trace is dropped by the pre-filter, including the spans buffered so far, so no policy
+			// evaluates it. The trace is still tracked, so its later spans are dropped as well
+			spansDroppedByPreFilter += actualData.SpanCount
+			actualData.FinalDecision = sampling.Dropped
+			actualData.DecisionTime = time.Now()
+			actualData.ReceivedBatches = nil
+		}
+		finalDecision := actualData.FinalDecision
+		if finalDecision == sampling.Dropped || preFilterDecision == preFilterDrop {
+			// When the decision was already made otherwise, it's too late to drop the whole trace, so just
+			// the spans of the denied resource are dropped
+			actualData.Unlock()
+			spansDroppedByPreFilter += lenSpans
+			continue
+		}
+		if finalDecision == sampling.Unspecified || finalDecision == sampling.SecondChance {
+			// The decision is not made yet, so the spans are buffered along with the rest of the trace. The final
+			// decision is set and the batches are released under the same lock, so no spans can be lost in between
+			acceptedSpans := cfsp.addSpanCount(actualData, lenSpans)
+			spansOverTraceLimit += lenSpans - acceptedSpans
+			expedite := false
+			forceKept := finalDecision == sampling.Unspecified && preFilterDecision == preFilterKeep
+			var fastTrackedBatches []pdata.Traces
+			if acceptedSpans > 0 {
+				spans = spans[:acceptedSpans]
+				traceBatch := prepareTraceBatch(resourceSpans, spans)
+				actualData.ReceivedBatches = append(actualData.ReceivedBatches, traceBatch)
+				if forceKept {
+					forceKeepTrace(actualData)
+				}
+				if forceKept || (finalDecision == sampling.Unspecified && cfsp.fastTrackTrace(pdata.NewTraceID(id), actualData, traceBatch, acceptedSpans)) {
+					fastTrackedBatches = actualData.ReceivedBatches
+					actualData.ReceivedBatches = nil
+				} else {
+					expedite = cfsp.errorDecisionBatcher != nil && !actualData.ExpeditedDecision && hasErrorSpan(spans)
+					if expedite {
+						actualData.ExpeditedDecision = true
+					}
+				}
 			}
 			actualData.Unlock()
-
-			// This section is run in case the decision was already applied earlier
-			switch actualDecision {
-			case sampling.Pending:
-				// All process for pending done above, keep the case so it doesn't go to default.
-			case sampling.SecondChance:
-				// It shouldn't normally get here, keep the case so it doesn't go to default, like above.
-			case sampling.Sampled:
-				// Forward the spans to the policy destinations
-				traceTd := prepareTraceBatch(resourceSpans, spans)
-				if err := cfsp.nextConsumer.ConsumeTraces(policy.ctx, traceTd); err != nil {
-					cfsp.logger.Warn("Error sending late arrived spans to destination",
-						zap.String("policy", policy.Name),
-						zap.Error(err))
+			if expedite {
+				cfsp.errorDecisionBatcher.AddToCurrentBatch(pdata.NewTraceID(id))
+			}
+			if fastTrackedBatches != nil {
+				status := statusFastTracked
+				if forceKept {
+					status = statusForceKept
 				}
-				fallthrough // so OnLateArrivingSpans is also called for decision Sampled.
-			case sampling.NotSampled:
-				policy.Evaluator.OnLateArrivingSpans(actualDecision, spans)
-				stats.Record(cfsp.ctx, statLateSpanArrivalAfterDecision.M(int64(time.Since(actualData.DecisionTime)/time.Second)))
+				cfsp.forwardFastTracked(fastTrackedBatches, status)
+			}
+			continue
+		}
+		decisions := make([]sampling.Decision, len(actualData.Decisions))
+		copy(decisions, actualData.Decisions)
+		fastTracked := actualData.FastTracked
+		actualData.Unlock()
+
+		if fastTracked {
+			// No policy was evaluated for the trace (it was fast-tracked or force-kept by the pre-filter), so the late
+			// spans are forwarded regardless of the decisions
+			if err := cfsp.nextConsumer.ConsumeTraces(cfsp.ctx, prepareTraceBatch(resourceSpans, spans)); err != nil {
+				cfsp.logger.Warn("Error sending late arrived spans of fast-tracked trace", zap.Error(err))
+			}
+			stats.Record(cfsp.ctx, statLateSpanArrivalAfterDecision.M(int64(time.Since(actualData.DecisionTime)/time.Second)))
+			continue
+		}
 
+		// The decision was already made, so the spans arrived late and are handled without buffering
+		forwarded := false
+		for i, policy := range cfsp.policies {
+			switch decisions[i] {
+			case sampling.Sampled, sampling.SecondChance:
+				if finalDecision == sampling.Sampled {
+					// Forward the spans to the policy destinations
+					traceTd := prepareTraceBatch(resourceSpans, spans)
+					if err := cfsp.nextConsumer.ConsumeTraces(policy.ctx, traceTd); err != nil {
+						cfsp.logger.Warn("Error sending late arrived spans to destination",
+							zap.String("policy", policy.Name),
+							zap.Error(err))
+					}
+					forwarded = true
+				}
+				policy.Evaluator.OnLateArrivingSpans(decisions[i], spans)
+			case sampling.NotSampled, sampling.Drop:
+				policy.Evaluator.OnLateArrivingSpans(decisions[i], spans)
 			default:
 				cfsp.logger.Warn("Encountered unexpected sampling decision",
 					zap.String("policy", policy.Name),
-					zap.Int("decision", int(actualDecision)))
+					zap.Int("decision", int(decisions[i])))
 			}
 
-			// At this point the late arrival has been passed to nextConsumer. Need to break out of the policy loop
-			// so that it isn't sent to nextConsumer more than once when multiple policies chose to sample
-			if actualDecision == sampling.Sampled {
+			// The late spans were passed to nextConsumer. Need to break out of the policy loop
+			// so that they aren't sent to nextConsumer more than once when multiple policies chose to sample
+			if forwarded {
 				break
 			}
 		}
+		stats.Record(cfsp.ctx, statLateSpanArrivalAfterDecision.M(int64(time.Since(actualData.DecisionTime)/time.Second)))
 	}
 
-	stats.Record(cfsp.ctx, statNewTraceIDReceivedCount.M(newTraceIDs))
+	stats.Record(cfsp.ctx,
+		statNewTraceIDReceivedCount.M(newTraceIDs),
+		statSpansOverTraceLimitCount.M(spansOverTraceLimit),
+		statSpansMissingRequiredAttrs.M(spansMissingRequiredAttrs),
+		statSpansDroppedByPreFilterCount.M(spansDroppedByPreFilter))
+}
+
+// fastTrackTrace samples the trace right away if the arriving spans match the fast-track policy and the trace fits
+// the global limit. The trace lock must be held when calling it.
+func (cfsp *cascadingFilterSpanProcessor) fastTrackTrace(id pdata.TraceID, trace *sampling.TraceData, batch pdata.Traces, numSpans int64) bool {
+	if cfsp.fastTrack == nil {
+		return false
+	}
+
+	arrivingSpans := &sampling.TraceData{ReceivedBatches: []pdata.Traces{batch}, SpanCount: numSpans}
+	if cfsp.fastTrack.Evaluate(id, arrivingSpans) != sampling.Sampled {
+		return false
+	}
+	if cfsp.updateRate(time.Now().Unix(), trace.SpanCount, nil) != sampling.Sampled {
+		// The trace is left for the regular decision
+		return false
+	}
+
+	trace.FinalDecision = sampling.Sampled
+	trace.FastTracked = true
+	trace.DecisionTime = time.Now()
+	return true
+}
+
+// forceKeepTrace samples the trace right away, regardless of the policies and limits. The trace lock must be held
+// when calling it.
+func forceKeepTrace(trace *sampling.TraceData) {
+	trace.FinalDecision = sampling.Sampled
+	trace.FastTracked = true
+	trace.DecisionTime = time.Now()
+}
+
+// forwardFastTracked sends the spans of the fast-tracked (or force-kept) trace received so far to the next consumer,
+// recording the decision with the given status
+func (cfsp *cascadingFilterSpanProcessor) forwardFastTracked(batches []pdata.Traces, status string) {
+	allSpans := combineBatches(batches)
+	updateFilteringTag(allSpans)
+
+	atomic.AddInt64(&cfsp.sampledTracesCount, 1)
+	_ = stats.RecordWithTags(
+		cfsp.ctx,
+		[]tag.Mutator{tag.Insert(tagCascadingFilterDecisionKey, status)},
+		statCascadingFilterDecision.M(int64(1)),
+	)
+
+	if err := cfsp.nextConsumer.ConsumeTraces(cfsp.ctx, allSpans); err != nil {
+		cfsp.logger.Warn("Error sending fast-tracked trace", zap.Error(err))
+	}
+}
+
+// addSpanCount increases the number of spans of the trace, but not above the max number of spans per trace.
+// It returns the number of spans which could be added. The trace lock must be held when calling it.
+func (cfsp *cascadingFilterSpanProcessor) addSpanCount(trace *sampling.TraceData, numSpans int64) int64 {
+	accepted := numSpans
+	if cfsp.maxSpansPerTrace > 0 {
+		if remaining := cfsp.maxSpansPerTrace - trace.SpanCount; remaining < accepted {
+			accepted = remaining
+		}
+		if accepted < 0 {
+			accepted = 0
+		}
+	}
+	atomic.AddInt64(&trace.SpanCount, accepted)
+	return accepted
 }
 
 func (cfsp *cascadingFilterSpanProcessor) GetCapabilities() component.ProcessorCapabilities {
@@ -527,15 +1305,74 @@ func (cfsp *cascadingFilterSpanProcessor) GetCapabilities() component.ProcessorC
 }
 
 // Start is invoked during service startup.
-func (cfsp *cascadingFilterSpanProcessor) Start(context.Context, component.Host) error {
+func (cfsp *cascadingFilterSpanProcessor) Start(_ context.Context, host component.Host) error {
+	if cfsp.droppedSample != nil {
+		tracesConsumer, err := findTracesExporter(host, cfsp.droppedSample.exporterName)
+		if err != nil {
+			return err
+		}
+		cfsp.droppedSample.consumer = tracesConsumer
+	}
+
+	if cfsp.metricsExporterName == "" {
+		return nil
+	}
+
+	metricsConsumer, err := findMetricsExporter(host, cfsp.metricsExporterName)
+	if err != nil {
+		return err
+	}
+	cfsp.metricsConsumer = metricsConsumer
+	cfsp.metricsStartTime = pdata.TimestampUnixNano(time.Now().UnixNano())
+	cfsp.metricsTicker.Start(cfsp.metricsEmitInterval)
 	return nil
 }
 
 // Shutdown is invoked during service shutdown.
 func (cfsp *cascadingFilterSpanProcessor) Shutdown(context.Context) error {
+	cfsp.stopPolicyTicker()
+	if cfsp.flushOnShutdown {
+		cfsp.flush()
+	}
+	if cfsp.metricsConsumer != nil {
+		cfsp.metricsTicker.Stop()
+	}
+	if cfsp.decisionLog != nil {
+		return cfsp.decisionLog.close()
+	}
 	return nil
 }
 
+// stopPolicyTicker stops the regular decisions, if they were started
+func (cfsp *cascadingFilterSpanProcessor) stopPolicyTicker() {
+	started := true
+	cfsp.start.Do(func() {
+		// The ticker is never started once the processor is shut down
+		started = false
+	})
+	if started {
+		cfsp.policyTicker.Stop()
+	}
+}
+
+// flush makes the final decision for all traces which are still pending, so the ones which would be sampled are not
+// lost on shutdown
+func (cfsp *cascadingFilterSpanProcessor) flush() {
+	var pending idbatcher.Batch
+	cfsp.idToTrace.Range(func(key, value interface{}) bool {
+		trace := value.(*sampling.TraceData)
+		trace.Lock()
+		if trace.FinalDecision == sampling.Unspecified {
+			pending = append(pending, pdata.NewTraceID(key.(traceKey)))
+		}
+		trace.Unlock()
+		return true
+	})
+
+	cfsp.logger.Info("Making the final decision for pending traces on shutdown", zap.Int("traces", len(pending)))
+	cfsp.decideBatch(time.Now(), pending)
+}
+
 func (cfsp *cascadingFilterSpanProcessor) dropTrace(traceID traceKey, deletionTime time.Time) {
 	var trace *sampling.TraceData
 	if d, ok := cfsp.idToTrace.Load(traceID); ok {
@@ -549,7 +1386,25 @@ func (cfsp *cascadingFilterSpanProcessor) dropTrace(traceID traceKey, deletionTi
 		return
 	}
 
-	stats.Record(cfsp.ctx, statTraceRemovalAgeSec.M(int64(deletionTime.Sub(trace.ArrivalTime)/time.Second)))
+	trace.Lock()
+	pending := trace.FinalDecision == sampling.Unspecified || trace.FinalDecision == sampling.SecondChance
+	trace.Unlock()
+
+	measurements := []stats.Measurement{statTraceRemovalAgeSec.M(int64(deletionTime.Sub(trace.ArrivalTime) / time.Second))}
+	if pending {
+		// The trace is evicted as the map is full, before its decision was made
+		measurements = append(measurements, statTracesEvictedBeforeDecision.M(int64(1)))
+	}
+	stats.Record(cfsp.ctx, measurements...)
+}
+
+func hasErrorSpan(spans []*pdata.Span) bool {
+	for _, span := range spans {
+		if span.Status().Code() == pdata.StatusCodeError {
+			return true
+		}
+	}
+	return false
 }
 
 func prepareTraceBatch(rss pdata.ResourceSpans, spans []*pdata.Span) pdata.Traces {
@@ -578,9 +1433,17 @@ type tTicker interface {
 type policyTicker struct {
 	ticker *time.Ticker
 	onTick func()
+	// jitter (optional) randomizes the start and the intervals of the ticks, it must be shorter than the interval
+	jitter time.Duration
+	// stopCh stops the ticks when jitter is used (and there is no ticker)
+	stopCh chan struct{}
 }
 
 func (pt *policyTicker) Start(d time.Duration) {
+	if pt.jitter > 0 {
+		pt.startWithJitter(d)
+		return
+	}
 	pt.ticker = time.NewTicker(d)
 	go func() {
 		for range pt.ticker.C {
@@ -592,7 +1455,35 @@ func (pt *policyTicker) OnTick() {
 	pt.onTick()
 }
 func (pt *policyTicker) Stop() {
+	if pt.stopCh != nil {
+		close(pt.stopCh)
+		return
+	}
 	pt.ticker.Stop()
 }
 
+// startWithJitter makes the first tick after a random delay up to the jitter, then each of the intervals is within
+// the jitter around d (so they are d on average)
+func (pt *policyTicker) startWithJitter(d time.Duration) {
+	pt.stopCh = make(chan struct{})
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(pt.jitter))))
+	go func() {
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+				pt.OnTick()
+				timer.Reset(pt.nextInterval(d))
+			case <-pt.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// nextInterval returns a random interval within [d-jitter, d+jitter)
+func (pt *policyTicker) nextInterval(d time.Duration) time.Duration {
+	return d - pt.jitter + time.Duration(rand.Int63n(int64(2*pt.jitter)))
+}
+
 var _ tTicker = (*policyTicker)(nil)
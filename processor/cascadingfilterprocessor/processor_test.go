@@ -17,14 +17,21 @@ package cascadingfilterprocessor
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/translator/conventions"
 	tracetranslator "go.opentelemetry.io/collector/translator/trace"
 	"go.uber.org/zap"
 
@@ -403,6 +410,1223 @@ func TestMultipleBatchesAreCombinedIntoOne(t *testing.T) {
 	}
 }
 
+func TestPendingTraceBuffersAllBatches(t *testing.T) {
+	const decisionWaitSeconds = 3
+	msp := new(consumertest.TracesSink)
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+		withDecisionWait(decisionWaitSeconds),
+	)
+
+	// The batches of the same trace keep arriving while the decision is pending
+	traceID := pdata.NewTraceID([16]byte{1})
+	for i := 0; i < decisionWaitSeconds; i++ {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(traceID, i+1)))
+		tsp.samplingPolicyOnTick()
+		require.Equal(t, 0, mpe.EvaluationCount)
+	}
+
+	d, ok := tsp.idToTrace.Load(traceKey(traceID.Bytes()))
+	require.True(t, ok)
+	trace := d.(*sampling.TraceData)
+	require.EqualValues(t, 6, trace.SpanCount)
+	require.Len(t, trace.ReceivedBatches, decisionWaitSeconds)
+
+	tsp.samplingPolicyOnTick()
+	require.Equal(t, 1, mpe.EvaluationCount)
+	require.Len(t, msp.AllTraces(), 1, "all batches should be forwarded as a single trace")
+	require.Equal(t, 6, msp.SpansCount())
+	require.Empty(t, trace.ReceivedBatches)
+}
+
+func TestLateSpansOfRateExceededTraceAreDropped(t *testing.T) {
+	const decisionWaitSeconds = 1
+	msp := new(consumertest.TracesSink)
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+		withDecisionWait(decisionWaitSeconds),
+		withMaxSpansPerSecond(1),
+	)
+
+	traceID := pdata.NewTraceID([16]byte{1})
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(traceID, 2)))
+	for i := 0; i <= decisionWaitSeconds; i++ {
+		tsp.samplingPolicyOnTick()
+	}
+	require.Equal(t, 1, mpe.EvaluationCount)
+	require.Equal(t, 0, msp.SpansCount(), "the trace exceeds the global limit")
+
+	// The policy selected the trace, but it was not sampled eventually, so the late span must not be forwarded
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(traceID, 1)))
+	require.Equal(t, 0, msp.SpansCount())
+	require.Equal(t, 1, mpe.LateArrivingSpansCount)
+}
+
+func TestPolicySamplingProbability(t *testing.T) {
+	cases := []struct {
+		Desc     string
+		Decision sampling.Decision
+	}{
+		{Desc: "selected by policy", Decision: sampling.Sampled},
+		{Desc: "second chance", Decision: sampling.SecondChance},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			const decisionWaitSeconds = 1
+			msp := new(consumertest.TracesSink)
+			mpe := &mockPolicyEvaluator{NextDecision: c.Decision}
+			tsp := newTestProcessor(t,
+				withNextConsumer(msp),
+				withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+				withDecisionWait(decisionWaitSeconds),
+				// Traces having 1, 2 and 3 spans are selected, only the first two fit the limit
+				withMaxSpansPerSecond(3),
+				func(cfsp *cascadingFilterSpanProcessor) {
+					cfsp.emitPolicySamplingProbability = true
+				},
+			)
+
+			_, batches := generateIdsAndBatches(3)
+			for _, batch := range batches {
+				require.NoError(t, tsp.ConsumeTraces(context.Background(), batch))
+			}
+
+			tsp.samplingPolicyOnTick()
+			tsp.samplingPolicyOnTick()
+
+			require.Equal(t, 3, msp.SpansCount())
+			for _, trace := range msp.AllTraces() {
+				for _, spanAttrs := range collectSpanAttributes(&trace) {
+					probability, found := spanAttrs.Get(conventions.AttributeSamplingProbability)
+					require.True(t, found)
+					require.Equal(t, 0.5, probability.DoubleVal())
+				}
+			}
+		})
+	}
+}
+
+func TestPolicySamplingProbabilityAccountsPolicyRateLimit(t *testing.T) {
+	msp := new(consumertest.TracesSink)
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		// Traces having 1, 2 and 3 spans match the policy, only the first one fits its limit
+		withPolicies(&Policy{Name: "limited-policy", Evaluator: &budgetPolicyEvaluator{budget: 1}, ctx: context.TODO()}),
+		func(cfsp *cascadingFilterSpanProcessor) {
+			cfsp.emitPolicySamplingProbability = true
+		},
+	)
+
+	_, batches := generateIdsAndBatches(3)
+	for _, batch := range batches {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), batch))
+	}
+
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	require.Equal(t, 1, msp.SpansCount())
+	for _, trace := range msp.AllTraces() {
+		for _, spanAttrs := range collectSpanAttributes(&trace) {
+			probability, found := spanAttrs.Get(conventions.AttributeSamplingProbability)
+			require.True(t, found)
+			require.InDelta(t, 1.0/6.0, probability.DoubleVal(), 1e-9)
+		}
+	}
+}
+
+func TestCustomProbabilityAttributeKey(t *testing.T) {
+	const customKey = "sample.rate"
+	msp := new(consumertest.TracesSink)
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(
+			&Policy{
+				Name:                probabilisticFilterPolicyName,
+				Evaluator:           &mockPolicyEvaluator{NextDecision: sampling.Sampled},
+				ctx:                 context.TODO(),
+				probabilisticFilter: true,
+			},
+		),
+		func(cfsp *cascadingFilterSpanProcessor) {
+			cfsp.probabilityAttributeKey = customKey
+		},
+	)
+
+	// The probability already set under the custom key (e.g. by head-based sampling) is multiplied
+	traces := simpleTracesWithID(pdata.NewTraceID([16]byte{1}))
+	traces.ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans().At(0).Attributes().UpsertDouble(customKey, 0.5)
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), traces))
+
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	require.Equal(t, 1, msp.SpansCount())
+	for _, trace := range msp.AllTraces() {
+		for _, spanAttrs := range collectSpanAttributes(&trace) {
+			probability, found := spanAttrs.Get(customKey)
+			require.True(t, found)
+			require.Equal(t, 0.5, probability.DoubleVal())
+			_, found = spanAttrs.Get(conventions.AttributeSamplingProbability)
+			require.False(t, found)
+		}
+	}
+}
+
+func TestUpstreamSamplingProbabilityIsCombined(t *testing.T) {
+	cases := []struct {
+		Desc                string
+		UpstreamProbability float64
+		ExpectedProbability float64
+	}{
+		{Desc: "without upstream probability", ExpectedProbability: 0.5},
+		{Desc: "with upstream probability", UpstreamProbability: 0.1, ExpectedProbability: 0.05},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			msp := new(consumertest.TracesSink)
+			eval, err := getProbabilisticFilterEvaluator(zap.NewNop(), 2, 0, conventions.AttributeSamplingProbability)
+			require.NoError(t, err)
+			tsp := newTestProcessor(t,
+				withNextConsumer(msp),
+				withPolicies(&Policy{Name: probabilisticFilterPolicyName, Evaluator: eval, ctx: context.TODO(), probabilisticFilter: true}),
+			)
+
+			// The first trace fits the budget of the probabilistic filter, so half of the spans is selected.
+			// The upstream probability is set only on one of the spans of the trace
+			selectedTraceID := pdata.NewTraceID([16]byte{1})
+			selected := tracesWithSpans(selectedTraceID, 2)
+			if c.UpstreamProbability > 0 {
+				selected.ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans().At(0).Attributes().
+					UpsertDouble(conventions.AttributeSamplingProbability, c.UpstreamProbability)
+			}
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), selected))
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(pdata.NewTraceID([16]byte{2}), 2)))
+
+			tsp.samplingPolicyOnTick()
+			tsp.samplingPolicyOnTick()
+
+			require.Equal(t, 2, msp.SpansCount())
+			trace := findTrace(msp.AllTraces(), selectedTraceID)
+			require.NotNil(t, trace)
+			for _, spanAttrs := range collectSpanAttributes(trace) {
+				probability, found := spanAttrs.Get(conventions.AttributeSamplingProbability)
+				require.True(t, found)
+				require.InDelta(t, c.ExpectedProbability, probability.DoubleVal(), 1e-9)
+			}
+		})
+	}
+}
+
+func TestPolicySamplingProbabilityNotEmittedByDefault(t *testing.T) {
+	const decisionWaitSeconds = 1
+	msp := new(consumertest.TracesSink)
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+		withDecisionWait(decisionWaitSeconds),
+	)
+
+	_, batches := generateIdsAndBatches(3)
+	for _, batch := range batches {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), batch))
+	}
+
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	require.Equal(t, 6, msp.SpansCount())
+	for _, trace := range msp.AllTraces() {
+		for _, spanAttrs := range collectSpanAttributes(&trace) {
+			_, found := spanAttrs.Get(conventions.AttributeSamplingProbability)
+			require.False(t, found)
+		}
+	}
+}
+
+func TestDecisionTimeIsEmitted(t *testing.T) {
+	cases := []struct {
+		Desc     string
+		Emit     bool
+		Expected bool
+	}{
+		{Desc: "emitted", Emit: true, Expected: true},
+		{Desc: "not emitted by default", Emit: false, Expected: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			msp := new(consumertest.TracesSink)
+			mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+			tsp := newTestProcessor(t,
+				withNextConsumer(msp),
+				withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+				func(cfsp *cascadingFilterSpanProcessor) {
+					cfsp.emitDecisionTime = c.Emit
+				},
+			)
+
+			_, batches := generateIdsAndBatches(3)
+			for _, batch := range batches {
+				require.NoError(t, tsp.ConsumeTraces(context.Background(), batch))
+			}
+
+			before := time.Now()
+			tsp.samplingPolicyOnTick()
+			tsp.samplingPolicyOnTick()
+			after := time.Now()
+
+			require.Equal(t, 6, msp.SpansCount())
+			for _, trace := range msp.AllTraces() {
+				rs := trace.ResourceSpans()
+				for i := 0; i < rs.Len(); i++ {
+					av, found := rs.At(i).Resource().Attributes().Get(AttributeDecisionTime)
+					require.Equal(t, c.Expected, found)
+					if c.Expected {
+						require.Equal(t, pdata.AttributeValueINT, av.Type())
+						require.GreaterOrEqual(t, av.IntVal(), before.UnixNano())
+						require.LessOrEqual(t, av.IntVal(), after.UnixNano())
+					}
+				}
+			}
+		})
+	}
+}
+
+func collectSpanAttributes(trace *pdata.Traces) []pdata.AttributeMap {
+	var attrs []pdata.AttributeMap
+
+	for i := 0; i < trace.ResourceSpans().Len(); i++ {
+		ilss := trace.ResourceSpans().At(i).InstrumentationLibrarySpans()
+
+		for j := 0; j < ilss.Len(); j++ {
+			ils := ilss.At(j)
+
+			for k := 0; k < ils.Spans().Len(); k++ {
+				attrs = append(attrs, ils.Spans().At(k).Attributes())
+			}
+		}
+	}
+
+	return attrs
+}
+
+func TestErrorTraceDecisionWait(t *testing.T) {
+	const decisionWaitSeconds = 5
+	const errorTraceDecisionWaitSeconds = 1
+	msp := new(consumertest.TracesSink)
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+		withDecisionWait(decisionWaitSeconds),
+		func(cfsp *cascadingFilterSpanProcessor) {
+			cfsp.errorDecisionBatcher = newSyncIDBatcher(errorTraceDecisionWaitSeconds)
+		},
+	)
+
+	errorTrace := simpleTracesWithID(pdata.NewTraceID([16]byte{1}))
+	errorTrace.ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans().At(0).Status().SetCode(pdata.StatusCodeError)
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), errorTrace))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(pdata.NewTraceID([16]byte{2}))))
+
+	// The error trace is decided after the shortened wait
+	for i := 0; i <= errorTraceDecisionWaitSeconds; i++ {
+		tsp.samplingPolicyOnTick()
+	}
+	require.Equal(t, 1, msp.SpansCount(), "error trace should have been decided before the decision wait")
+	require.Equal(t, 1, mpe.EvaluationCount)
+
+	// The other trace is decided after the regular wait, while the error one is not evaluated again
+	for i := errorTraceDecisionWaitSeconds + 1; i <= decisionWaitSeconds; i++ {
+		tsp.samplingPolicyOnTick()
+	}
+	require.Equal(t, 2, msp.SpansCount())
+	require.Equal(t, 2, mpe.EvaluationCount)
+	require.Len(t, msp.AllTraces(), 2)
+}
+
+func TestErrorTraceDecisionWaitMustBeWholeSeconds(t *testing.T) {
+	for _, wait := range []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond} {
+		cfg := config.Config{
+			DecisionWait:            5 * time.Second,
+			ErrorTraceDecisionWait:  wait,
+			NumTraces:               100,
+			ExpectedNewTracesPerSec: 64,
+			PolicyCfgs:              testPolicy,
+		}
+		_, err := newTraceProcessor(zap.NewNop(), consumertest.NewTracesNop(), cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "whole number of seconds")
+	}
+}
+
+func TestErrorTraceDecisionWaitMustBeShorter(t *testing.T) {
+	cfg := config.Config{
+		DecisionWait:            5 * time.Second,
+		ErrorTraceDecisionWait:  5 * time.Second,
+		NumTraces:               100,
+		ExpectedNewTracesPerSec: 64,
+		PolicyCfgs:              testPolicy,
+	}
+	_, err := newTraceProcessor(zap.NewNop(), consumertest.NewTracesNop(), cfg)
+	require.Error(t, err)
+}
+
+func TestTickJitterValidation(t *testing.T) {
+	for _, jitter := range []time.Duration{-time.Millisecond, time.Second} {
+		cfg := config.Config{
+			DecisionWait:            5 * time.Second,
+			TickJitter:              jitter,
+			NumTraces:               100,
+			ExpectedNewTracesPerSec: 64,
+			PolicyCfgs:              testPolicy,
+		}
+		_, err := newTraceProcessor(zap.NewNop(), consumertest.NewTracesNop(), cfg)
+		require.Error(t, err)
+	}
+}
+
+func TestPolicyTickerJitter(t *testing.T) {
+	const jitter = 200 * time.Millisecond
+	pt := &policyTicker{onTick: func() {}, jitter: jitter}
+
+	intervals := make(map[time.Duration]struct{})
+	for i := 0; i < 100; i++ {
+		interval := pt.nextInterval(time.Second)
+		require.GreaterOrEqual(t, int64(interval), int64(time.Second-jitter))
+		require.Less(t, int64(interval), int64(time.Second+jitter))
+		intervals[interval] = struct{}{}
+	}
+	require.Greater(t, len(intervals), 1, "intervals should vary")
+}
+
+func TestPolicyTickerWithJitterTicks(t *testing.T) {
+	var ticks int64
+	pt := &policyTicker{onTick: func() { atomic.AddInt64(&ticks, 1) }, jitter: 5 * time.Millisecond}
+
+	pt.Start(10 * time.Millisecond)
+	require.Eventually(t, func() bool { return atomic.LoadInt64(&ticks) >= 3 }, time.Second, time.Millisecond)
+	pt.Stop()
+
+	// No more ticks are made once stopped
+	stoppedTicks := atomic.LoadInt64(&ticks)
+	time.Sleep(50 * time.Millisecond)
+	require.LessOrEqual(t, atomic.LoadInt64(&ticks), stoppedTicks+1)
+}
+
+func TestMaxSpansPerTrace(t *testing.T) {
+	views := CascadingFilterMetricViews(configtelemetry.LevelNormal)
+	view.Unregister(views...)
+	require.NoError(t, view.Register(views...))
+	defer view.Unregister(views...)
+
+	const decisionWaitSeconds = 5
+	const maxSpansPerTrace = 3
+	msp := new(consumertest.TracesSink)
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+		withDecisionWait(decisionWaitSeconds),
+		func(cfsp *cascadingFilterSpanProcessor) {
+			cfsp.maxSpansPerTrace = maxSpansPerTrace
+		},
+	)
+
+	traceID := pdata.NewTraceID([16]byte{1})
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(traceID, 2)))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(traceID, 2)))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(traceID, 1)))
+
+	d, ok := tsp.idToTrace.Load(traceKey(traceID.Bytes()))
+	require.True(t, ok, "trace should be kept in memory")
+	require.EqualValues(t, maxSpansPerTrace, d.(*sampling.TraceData).SpanCount)
+
+	viewData, err := view.RetrieveData("processor/cascading_filter/" + statSpansOverTraceLimitCount.Name())
+	require.NoError(t, err)
+	require.Len(t, viewData, 1)
+	require.EqualValues(t, 2, viewData[0].Data.(*view.SumData).Value)
+
+	for i := 0; i <= decisionWaitSeconds; i++ {
+		tsp.samplingPolicyOnTick()
+	}
+	require.Equal(t, maxSpansPerTrace, msp.SpansCount(), "only spans within the limit should be forwarded")
+}
+
+func TestReservedBudgetIsHonored(t *testing.T) {
+	cases := []struct {
+		Desc                string
+		ReservedBudgetRatio float64
+		ExpectedSpans       int
+		SmallTraceSampled   bool
+	}{
+		{Desc: "without reservation", ReservedBudgetRatio: 0, ExpectedSpans: 10, SmallTraceSampled: false},
+		{Desc: "with reservation", ReservedBudgetRatio: 0.3, ExpectedSpans: 7, SmallTraceSampled: true},
+	}
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			const decisionWaitSeconds = 1
+			msp := new(consumertest.TracesSink)
+			noisy := &spanCountPolicyEvaluator{minSpans: 5}
+			reserved := &spanCountPolicyEvaluator{minSpans: 1}
+			reservedPolicy := &Policy{
+				Name:                   "reserved-policy",
+				Evaluator:              reserved,
+				ctx:                    context.TODO(),
+				reservedSpansPerSecond: int64(10 * c.ReservedBudgetRatio),
+			}
+			tsp := newTestProcessor(t,
+				withNextConsumer(msp),
+				withPolicies(
+					&Policy{Name: "noisy-policy", Evaluator: noisy, ctx: context.TODO()},
+					reservedPolicy,
+				),
+				withDecisionWait(decisionWaitSeconds),
+				withMaxSpansPerSecond(10),
+			)
+
+			// The noisy policy alone would use the whole global budget with the first two traces
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(pdata.NewTraceID([16]byte{1}), 5)))
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(pdata.NewTraceID([16]byte{2}), 5)))
+			smallTraceID := pdata.NewTraceID([16]byte{3})
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(smallTraceID, 2)))
+			for i := 0; i <= decisionWaitSeconds; i++ {
+				tsp.samplingPolicyOnTick()
+			}
+
+			require.Equal(t, c.ExpectedSpans, msp.SpansCount())
+			require.Equal(t, c.SmallTraceSampled, findTrace(msp.AllTraces(), smallTraceID) != nil)
+			require.LessOrEqual(t, tsp.spansInCurrentSecond, tsp.maxSpansPerSecond)
+		})
+	}
+}
+
+func TestRateLimitMetrics(t *testing.T) {
+	views := CascadingFilterMetricViews(configtelemetry.LevelNormal)
+	view.Unregister(views...)
+	require.NoError(t, view.Register(views...))
+	defer view.Unregister(views...)
+
+	tsp := newTestProcessor(t,
+		withPolicies(&Policy{Name: "all-policy", Evaluator: &spanCountPolicyEvaluator{minSpans: 1}, ctx: context.TODO()}),
+		withMaxSpansPerSecond(10),
+	)
+
+	// Only the first trace fits the global limit
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(pdata.NewTraceID([16]byte{1}), 6)))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(pdata.NewTraceID([16]byte{2}), 6)))
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	droppedData, err := view.RetrieveData("processor/cascading_filter/" + statSpansOverRateLimitCount.Name())
+	require.NoError(t, err)
+	require.Len(t, droppedData, 1)
+	require.EqualValues(t, 6, droppedData[0].Data.(*view.SumData).Value)
+
+	remainingData, err := view.RetrieveData("processor/cascading_filter/" + statRemainingSpansPerSecondGauge.Name())
+	require.NoError(t, err)
+	require.Len(t, remainingData, 1)
+	require.EqualValues(t, tsp.maxSpansPerSecond-tsp.spansInCurrentSecond, remainingData[0].Data.(*view.LastValueData).Value)
+}
+
+func TestTracesEvictedBeforeDecisionMetric(t *testing.T) {
+	views := CascadingFilterMetricViews(configtelemetry.LevelNormal)
+	view.Unregister(views...)
+	require.NoError(t, view.Register(views...))
+	defer view.Unregister(views...)
+
+	const maxSize = 2
+	tsp := newTestProcessor(t,
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: &mockPolicyEvaluator{NextDecision: sampling.Sampled}, ctx: context.TODO()}),
+		withMaxNumTraces(maxSize),
+	)
+
+	// The first two traces are decided before being evicted
+	for i := 1; i <= 2; i++ {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(pdata.NewTraceID([16]byte{byte(i)}))))
+	}
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	// The third one is still pending when the fifth one arrives
+	for i := 3; i <= 5; i++ {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(pdata.NewTraceID([16]byte{byte(i)}))))
+	}
+
+	evictedData, err := view.RetrieveData("processor/cascading_filter/" + statTracesEvictedBeforeDecision.Name())
+	require.NoError(t, err)
+	require.Len(t, evictedData, 1)
+	require.EqualValues(t, 1, evictedData[0].Data.(*view.SumData).Value)
+
+	removalData, err := view.RetrieveData("processor/cascading_filter/" + statTraceRemovalAgeSec.Name())
+	require.NoError(t, err)
+	require.Len(t, removalData, 1)
+	require.EqualValues(t, 3, removalData[0].Data.(*view.DistributionData).Count)
+}
+
+func TestSpansMissingRequiredAttributesAreDropped(t *testing.T) {
+	views := CascadingFilterMetricViews(configtelemetry.LevelNormal)
+	view.Unregister(views...)
+	require.NoError(t, view.Register(views...))
+	defer view.Unregister(views...)
+
+	msp := new(consumertest.TracesSink)
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+		func(cfsp *cascadingFilterSpanProcessor) {
+			cfsp.requiredSpanAttributes = []string{"service.version", "tenant.id"}
+		},
+	)
+
+	// Only the first span of the trace has all required attributes
+	traceID := pdata.NewTraceID([16]byte{1})
+	traces := tracesWithSpans(traceID, 3)
+	spans := traces.ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans()
+	spans.At(0).Attributes().UpsertString("service.version", "1.0")
+	spans.At(0).Attributes().UpsertString("tenant.id", "tenant-a")
+	spans.At(1).Attributes().UpsertString("service.version", "1.0")
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), traces))
+
+	// None of the spans of this trace has them, so it's not even stored
+	malformedTraceID := pdata.NewTraceID([16]byte{2})
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(malformedTraceID, 2)))
+
+	d, ok := tsp.idToTrace.Load(traceKey(traceID.Bytes()))
+	require.True(t, ok)
+	require.EqualValues(t, 1, d.(*sampling.TraceData).SpanCount)
+	_, ok = tsp.idToTrace.Load(traceKey(malformedTraceID.Bytes()))
+	require.False(t, ok)
+
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	require.Equal(t, 1, mpe.EvaluationCount)
+	require.Equal(t, 1, msp.SpansCount())
+
+	droppedData, err := view.RetrieveData("processor/cascading_filter/" + statSpansMissingRequiredAttrs.Name())
+	require.NoError(t, err)
+	require.Len(t, droppedData, 1)
+	require.EqualValues(t, 4, droppedData[0].Data.(*view.SumData).Value)
+}
+
+func TestFlushOnShutdown(t *testing.T) {
+	cases := []struct {
+		Desc          string
+		Flush         bool
+		ExpectedSpans int
+	}{
+		{Desc: "pending traces are dropped", Flush: false, ExpectedSpans: 1},
+		{Desc: "pending traces are flushed", Flush: true, ExpectedSpans: 3},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			msp := new(consumertest.TracesSink)
+			sampledTraceID := pdata.NewTraceID([16]byte{1})
+			pendingTraceID := pdata.NewTraceID([16]byte{2})
+			notSampledTraceID := pdata.NewTraceID([16]byte{3})
+			tsp := newTestProcessor(t,
+				withNextConsumer(msp),
+				withPolicies(&Policy{Name: "selecting", Evaluator: newSelectingPolicyEvaluator(sampling.Sampled, sampledTraceID, pendingTraceID), ctx: context.TODO()}),
+				func(cfsp *cascadingFilterSpanProcessor) {
+					cfsp.flushOnShutdown = c.Flush
+				},
+			)
+
+			// The first trace is decided before shutdown, while the other ones are still pending
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(sampledTraceID)))
+			tsp.samplingPolicyOnTick()
+			tsp.samplingPolicyOnTick()
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(pendingTraceID, 2)))
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(notSampledTraceID)))
+
+			require.NoError(t, tsp.Shutdown(context.Background()))
+
+			// The regular decisions are stopped regardless of flushing
+			require.True(t, tsp.policyTicker.(*manualTTicker).Stopped)
+			require.Equal(t, c.ExpectedSpans, msp.SpansCount())
+			if c.Flush {
+				require.NotNil(t, findTrace(msp.AllTraces(), pendingTraceID))
+
+				d, ok := tsp.idToTrace.Load(traceKey(notSampledTraceID.Bytes()))
+				require.True(t, ok)
+				require.Equal(t, sampling.NotSampled, d.(*sampling.TraceData).FinalDecision)
+			}
+		})
+	}
+}
+
+func TestFlushOnShutdownBeforeFirstTrace(t *testing.T) {
+	tsp := &cascadingFilterSpanProcessor{
+		ctx:             context.Background(),
+		nextConsumer:    consumertest.NewTracesNop(),
+		maxNumTraces:    100,
+		logger:          zap.NewNop(),
+		decisionBatcher: newSyncIDBatcher(1),
+		// The ticker was never started, so it must not be stopped
+		policyTicker:    &policyTicker{onTick: func() {}},
+		flushOnShutdown: true,
+	}
+	require.NoError(t, tsp.Shutdown(context.Background()))
+}
+
+func TestReservedBudgetRatioValidation(t *testing.T) {
+	cases := []struct {
+		Desc   string
+		Ratios []float64
+	}{
+		{Desc: "negative ratio", Ratios: []float64{-0.1}},
+		{Desc: "ratio above one", Ratios: []float64{1.5}},
+		{Desc: "ratios exceeding one in total", Ratios: []float64{0.6, 0.5}},
+	}
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			var policyCfgs []config.PolicyCfg
+			for i, ratio := range c.Ratios {
+				policyCfgs = append(policyCfgs, config.PolicyCfg{
+					Name:                fmt.Sprintf("policy-%d", i),
+					SpansPerSecond:      1000,
+					ReservedBudgetRatio: ratio,
+				})
+			}
+			cfg := config.Config{
+				DecisionWait:            5 * time.Second,
+				NumTraces:               100,
+				ExpectedNewTracesPerSec: 64,
+				SpansPerSecond:          1000,
+				PolicyCfgs:              policyCfgs,
+			}
+			_, err := newTraceProcessor(zap.NewNop(), consumertest.NewTracesNop(), cfg)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestConcurrentPolicyEvaluationMatchesSequential(t *testing.T) {
+	const numTraces = 200
+	minSpans := 3
+	policyCfgs := []config.PolicyCfg{
+		{
+			Name:                "numeric",
+			SpansPerSecond:      100000,
+			NumericAttributeCfg: &config.NumericAttributeCfg{Key: "key1", MinValue: 50, MaxValue: 100},
+		},
+		{
+			Name:               "string",
+			SpansPerSecond:     100000,
+			StringAttributeCfg: &config.StringAttributeCfg{Key: "key2", Values: []string{"value1"}},
+		},
+		{
+			Name:           "min-spans",
+			SpansPerSecond: 100000,
+			PropertiesCfg:  config.PropertiesCfg{MinNumberOfSpans: &minSpans},
+		},
+		{
+			Name:                "inverted-numeric",
+			SpansPerSecond:      100000,
+			NumericAttributeCfg: &config.NumericAttributeCfg{Key: "key1", MinValue: 0, MaxValue: 10},
+			InvertMatch:         true,
+		},
+		{
+			// Traces never fit this limit
+			Name:           "too-small",
+			SpansPerSecond: 1,
+		},
+		{
+			Name:           "everything_else",
+			SpansPerSecond: -1,
+		},
+	}
+
+	run := func(concurrency int) (map[traceKey]*sampling.TraceData, int) {
+		msp := new(consumertest.TracesSink)
+		tsp, err := newCascadingFilterSpanProcessor(zap.NewNop(), msp, config.Config{
+			DecisionWait:                time.Second,
+			NumTraces:                   2 * numTraces,
+			ExpectedNewTracesPerSec:     64,
+			SpansPerSecond:              100000,
+			PolicyEvaluationConcurrency: concurrency,
+			PolicyCfgs:                  policyCfgs,
+		})
+		require.NoError(t, err)
+		tsp.decisionBatcher = newSyncIDBatcher(1)
+		tsp.policyTicker = &manualTTicker{}
+
+		for i := 0; i < numTraces; i++ {
+			traceID := tracetranslator.UInt64ToTraceID(1, uint64(i+1))
+			traces := tracesWithSpans(traceID, 2+i%4)
+			attrs := traces.ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans().At(0).Attributes()
+			attrs.UpsertInt("key1", int64(i%100))
+			attrs.UpsertString("key2", fmt.Sprintf("value%d", i%3))
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), traces))
+		}
+		tsp.samplingPolicyOnTick()
+		tsp.samplingPolicyOnTick()
+
+		traces := make(map[traceKey]*sampling.TraceData)
+		tsp.idToTrace.Range(func(key, value interface{}) bool {
+			traces[key.(traceKey)] = value.(*sampling.TraceData)
+			return true
+		})
+		return traces, msp.SpansCount()
+	}
+
+	sequentialTraces, sequentialSpans := run(0)
+	concurrentTraces, concurrentSpans := run(4)
+
+	require.Len(t, sequentialTraces, numTraces)
+	require.Len(t, concurrentTraces, numTraces)
+	for id, sequential := range sequentialTraces {
+		concurrent, ok := concurrentTraces[id]
+		require.True(t, ok)
+		require.Equal(t, sequential.Decisions, concurrent.Decisions)
+		require.Equal(t, sequential.FinalDecision, concurrent.FinalDecision)
+	}
+	require.Equal(t, sequentialSpans, concurrentSpans)
+}
+
+func TestNegativePolicyEvaluationConcurrency(t *testing.T) {
+	cfg := config.Config{
+		DecisionWait:                5 * time.Second,
+		NumTraces:                   100,
+		ExpectedNewTracesPerSec:     64,
+		PolicyEvaluationConcurrency: -1,
+		PolicyCfgs:                  testPolicy,
+	}
+	_, err := newTraceProcessor(zap.NewNop(), consumertest.NewTracesNop(), cfg)
+	require.Error(t, err)
+}
+
+func BenchmarkPolicyEvaluation(b *testing.B) {
+	const numPolicies = 16
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			var policies []*Policy
+			for i := 0; i < numPolicies; i++ {
+				policies = append(policies, &Policy{
+					Name:      fmt.Sprintf("policy-%d", i),
+					Evaluator: &slowPolicyEvaluator{delay: 100 * time.Microsecond},
+					ctx:       context.TODO(),
+				})
+			}
+			tsp := &cascadingFilterSpanProcessor{
+				ctx:                         context.Background(),
+				logger:                      zap.NewNop(),
+				policies:                    policies,
+				policyEvaluationConcurrency: concurrency,
+			}
+			traceID := pdata.NewTraceID([16]byte{1})
+			trace := &sampling.TraceData{
+				Decisions:       make([]sampling.Decision, numPolicies),
+				SpanCount:       1,
+				ReceivedBatches: []pdata.Traces{simpleTracesWithID(traceID)},
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tsp.makeProvisionalDecision(traceID, trace)
+			}
+		})
+	}
+}
+
+func TestDropOverridesSampled(t *testing.T) {
+	msp := new(consumertest.TracesSink)
+	droppedTraceID := pdata.NewTraceID([16]byte{1})
+	keptTraceID := pdata.NewTraceID([16]byte{2})
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(
+			&Policy{Name: "sample-all", Evaluator: &mockPolicyEvaluator{NextDecision: sampling.Sampled}, ctx: context.TODO()},
+			&Policy{Name: "drop-synthetic", Evaluator: newSelectingPolicyEvaluator(sampling.Drop, droppedTraceID), ctx: context.TODO()},
+		),
+	)
+
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(droppedTraceID)))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(keptTraceID)))
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	require.Equal(t, 1, msp.SpansCount())
+	require.Nil(t, findTrace(msp.AllTraces(), droppedTraceID), "dropped trace should not be forwarded")
+	require.NotNil(t, findTrace(msp.AllTraces(), keptTraceID))
+
+	// Late spans of the dropped trace are not forwarded either
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(droppedTraceID)))
+	require.Equal(t, 1, msp.SpansCount())
+
+	policyStats := tsp.PolicyStats()
+	require.EqualValues(t, 2, policyStats["sample-all"].Sampled)
+	require.EqualValues(t, 1, policyStats["drop-synthetic"].Drop)
+}
+
+func TestNewDeploymentFallsBackToOtherPolicies(t *testing.T) {
+	msp := new(consumertest.TracesSink)
+	newDeployment, err := sampling.NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:             "new-deployment",
+		NewDeploymentCfg: &config.NewDeploymentCfg{Key: "service.version", MaxTraces: 2},
+		SpansPerSecond:   1000,
+	})
+	require.NoError(t, err)
+	selectedTraceID := pdata.NewTraceID([16]byte{4})
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(
+			&Policy{Name: "new-deployment", Evaluator: newDeployment, ctx: context.TODO()},
+			&Policy{Name: "selecting", Evaluator: newSelectingPolicyEvaluator(sampling.Sampled, selectedTraceID), ctx: context.TODO()},
+		),
+	)
+
+	// The first traces of the deployment are all sampled, the later ones only when selected by other policies
+	for i := byte(1); i <= 5; i++ {
+		traces := simpleTracesWithID(pdata.NewTraceID([16]byte{i}))
+		traces.ResourceSpans().At(0).Resource().Attributes().UpsertString("service.version", "v1")
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), traces))
+		tsp.samplingPolicyOnTick()
+		tsp.samplingPolicyOnTick()
+	}
+
+	require.Equal(t, 3, msp.SpansCount())
+	for _, id := range []byte{1, 2, 4} {
+		require.NotNil(t, findTrace(msp.AllTraces(), pdata.NewTraceID([16]byte{id})))
+	}
+
+	policyStats := tsp.PolicyStats()
+	require.EqualValues(t, 2, policyStats["new-deployment"].Sampled)
+	require.EqualValues(t, 1, policyStats["selecting"].Sampled)
+}
+
+func TestPolicyEffectiveKeepRate(t *testing.T) {
+	views := CascadingFilterMetricViews(configtelemetry.LevelNormal)
+	view.Unregister(views...)
+	require.NoError(t, view.Register(views...))
+	defer view.Unregister(views...)
+
+	policyCtx, err := tag.New(context.Background(), tag.Upsert(tagPolicyKey, "limited-policy"))
+	require.NoError(t, err)
+	tsp := newTestProcessor(t,
+		withPolicies(&Policy{Name: "limited-policy", Evaluator: &budgetPolicyEvaluator{budget: 2}, ctx: policyCtx, keepRate: newKeepRateWindow(time.Minute)}),
+	)
+
+	// All traces match the policy, but only two of them fit its budget
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(pdata.NewTraceID([16]byte{byte(i)}))))
+	}
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	require.Equal(t, map[string]float64{"limited-policy": 0.5}, tsp.PolicyEffectiveKeepRates())
+
+	viewData, err := view.RetrieveData("processor/cascading_filter/" + statPolicyEffectiveKeepRate.Name())
+	require.NoError(t, err)
+	require.Len(t, viewData, 1)
+	require.Equal(t, []tag.Tag{{Key: tagPolicyKey, Value: "limited-policy"}}, viewData[0].Tags)
+	require.Equal(t, 0.5, viewData[0].Data.(*view.LastValueData).Value)
+}
+
+func TestFastTrackedTraceIsForwardedImmediately(t *testing.T) {
+	const decisionWaitSeconds = 5
+	msp := new(consumertest.TracesSink)
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+	fastTrackedID := pdata.NewTraceID([16]byte{1})
+	regularID := pdata.NewTraceID([16]byte{2})
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+		withDecisionWait(decisionWaitSeconds),
+		func(cfsp *cascadingFilterSpanProcessor) {
+			cfsp.fastTrack = newSelectingPolicyEvaluator(sampling.Sampled, fastTrackedID)
+		},
+	)
+
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(fastTrackedID, 2)))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(regularID, 2)))
+
+	// The fast-tracked trace does not wait for the decision
+	require.Equal(t, 2, msp.SpansCount())
+	require.NotNil(t, findTrace(msp.AllTraces(), fastTrackedID))
+	require.Nil(t, findTrace(msp.AllTraces(), regularID))
+	require.EqualValues(t, 2, tsp.spansInCurrentSecond, "fast-tracked trace should use the global limit")
+
+	// Late spans of the fast-tracked trace are forwarded as they arrive
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(fastTrackedID)))
+	require.Equal(t, 3, msp.SpansCount())
+
+	for i := 0; i <= decisionWaitSeconds; i++ {
+		tsp.samplingPolicyOnTick()
+	}
+
+	// Only the regular trace is evaluated by the policies, the fast-tracked one is not forwarded again
+	require.Equal(t, 1, mpe.EvaluationCount)
+	require.Equal(t, 5, msp.SpansCount())
+	require.EqualValues(t, 2, tsp.sampledTracesCount)
+}
+
+func TestAdvisoryProbabilisticFilter(t *testing.T) {
+	cases := []struct {
+		Desc           string
+		Advisory       bool
+		PolicyDecision sampling.Decision
+		ExpectedPolicy string
+		ExpectedRule   string
+	}{
+		{
+			Desc:           "final probabilistic decision",
+			Advisory:       false,
+			PolicyDecision: sampling.Sampled,
+			ExpectedPolicy: probabilisticFilterPolicyName,
+			ExpectedRule:   probabilisticRuleVale,
+		},
+		{
+			Desc:           "advisory decision attributed to policy",
+			Advisory:       true,
+			PolicyDecision: sampling.Sampled,
+			ExpectedPolicy: "mock-policy",
+			ExpectedRule:   filteredRuleValue,
+		},
+		{
+			Desc:           "advisory decision keeping probabilistic-only trace",
+			Advisory:       true,
+			PolicyDecision: sampling.NotSampled,
+			ExpectedPolicy: probabilisticFilterPolicyName,
+			ExpectedRule:   probabilisticRuleVale,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			msp := new(consumertest.TracesSink)
+			tsp := newTestProcessor(t,
+				withNextConsumer(msp),
+				withPolicies(
+					&Policy{
+						Name:                probabilisticFilterPolicyName,
+						Evaluator:           &mockPolicyEvaluator{NextDecision: sampling.Sampled},
+						ctx:                 context.TODO(),
+						probabilisticFilter: true,
+					},
+					&Policy{Name: "mock-policy", Evaluator: &mockPolicyEvaluator{NextDecision: c.PolicyDecision}, ctx: context.TODO()},
+				),
+				func(cfsp *cascadingFilterSpanProcessor) {
+					cfsp.probabilisticFilterAdvisory = c.Advisory
+				},
+			)
+
+			traceID := pdata.NewTraceID([16]byte{1})
+			trace := &sampling.TraceData{
+				Decisions:       make([]sampling.Decision, len(tsp.policies)),
+				ReceivedBatches: []pdata.Traces{simpleTracesWithID(traceID)},
+			}
+			decision, policy := tsp.makeProvisionalDecision(traceID, trace)
+			require.Equal(t, sampling.Sampled, decision)
+			require.Equal(t, c.ExpectedPolicy, policy.Name)
+
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(traceID)))
+			tsp.samplingPolicyOnTick()
+			tsp.samplingPolicyOnTick()
+
+			require.Equal(t, 1, msp.SpansCount(), "the trace must be kept")
+			for _, attrs := range collectSpanAttributes(&msp.AllTraces()[0]) {
+				rule, ok := attrs.Get(AttributeSamplingRule)
+				require.True(t, ok)
+				require.Equal(t, c.ExpectedRule, rule.StringVal())
+			}
+		})
+	}
+}
+
+func TestSecondChancePriority(t *testing.T) {
+	lowPriorityTraceID := pdata.NewTraceID([16]byte{1})
+	highPriorityTraceID := pdata.NewTraceID([16]byte{2})
+
+	cases := []struct {
+		Desc            string
+		LowPriority     int
+		HighPriority    int
+		ExpectedTraceID pdata.TraceID
+	}{
+		{Desc: "equal priorities", LowPriority: 0, HighPriority: 0, ExpectedTraceID: lowPriorityTraceID},
+		{Desc: "different priorities", LowPriority: 0, HighPriority: 10, ExpectedTraceID: highPriorityTraceID},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			msp := new(consumertest.TracesSink)
+			tsp := newTestProcessor(t,
+				withNextConsumer(msp),
+				withPolicies(
+					&Policy{
+						Name:      "low-priority",
+						Evaluator: newSelectingPolicyEvaluator(sampling.SecondChance, lowPriorityTraceID),
+						ctx:       context.TODO(),
+						priority:  c.LowPriority,
+					},
+					&Policy{
+						Name:      "high-priority",
+						Evaluator: newSelectingPolicyEvaluator(sampling.SecondChance, highPriorityTraceID),
+						ctx:       context.TODO(),
+						priority:  c.HighPriority,
+					},
+				),
+				withMaxSpansPerSecond(4),
+			)
+
+			// Only one of the traces fits the global limit, the one of lower priority arrives first
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(lowPriorityTraceID, 3)))
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(highPriorityTraceID, 3)))
+			tsp.samplingPolicyOnTick()
+			tsp.samplingPolicyOnTick()
+
+			require.Equal(t, 3, msp.SpansCount())
+			require.NotNil(t, findTrace(msp.AllTraces(), c.ExpectedTraceID))
+		})
+	}
+}
+
+func TestPolicyEvaluationErrorsByPolicy(t *testing.T) {
+	views := CascadingFilterMetricViews(configtelemetry.LevelNormal)
+	view.Unregister(views...)
+	require.NoError(t, view.Register(views...))
+	defer view.Unregister(views...)
+
+	failingCtx, err := tag.New(context.Background(), tag.Upsert(tagPolicyKey, "failing-policy"))
+	require.NoError(t, err)
+	healthyCtx, err := tag.New(context.Background(), tag.Upsert(tagPolicyKey, "healthy-policy"))
+	require.NoError(t, err)
+
+	tsp := newTestProcessor(t,
+		withPolicies(
+			// An unexpected decision is treated as an evaluation error
+			&Policy{Name: "failing-policy", Evaluator: &mockPolicyEvaluator{NextDecision: sampling.Unspecified}, ctx: failingCtx},
+			&Policy{Name: "healthy-policy", Evaluator: &mockPolicyEvaluator{NextDecision: sampling.NotSampled}, ctx: healthyCtx},
+		),
+	)
+
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(pdata.NewTraceID([16]byte{1}))))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(pdata.NewTraceID([16]byte{2}))))
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	viewData, err := view.RetrieveData("processor/cascading_filter/" + statPolicyEvaluationErrorCount.Name())
+	require.NoError(t, err)
+	require.Len(t, viewData, 1, "only the failing policy should have errors recorded")
+	require.Equal(t, []tag.Tag{{Key: tagPolicyKey, Value: "failing-policy"}}, viewData[0].Tags)
+	require.EqualValues(t, 2, viewData[0].Data.(*view.SumData).Value)
+}
+
+func TestTraceAgeAtDecision(t *testing.T) {
+	views := CascadingFilterMetricViews(configtelemetry.LevelNormal)
+	view.Unregister(views...)
+	require.NoError(t, view.Register(views...))
+	defer view.Unregister(views...)
+
+	const decisionWaitSeconds = 5
+	msp := new(consumertest.TracesSink)
+	mpe := &mockPolicyEvaluator{NextDecision: sampling.Sampled}
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "mock-policy", Evaluator: mpe, ctx: context.TODO()}),
+		withDecisionWait(decisionWaitSeconds),
+	)
+
+	traceID := pdata.NewTraceID([16]byte{1})
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), simpleTracesWithID(traceID)))
+
+	// The ticker is driven manually, so the arrival is moved back by the wait time instead of waiting for it
+	d, ok := tsp.idToTrace.Load(traceKey(traceID.Bytes()))
+	require.True(t, ok)
+	d.(*sampling.TraceData).ArrivalTime = time.Now().Add(-decisionWaitSeconds * time.Second)
+
+	for i := 0; i <= decisionWaitSeconds; i++ {
+		tsp.samplingPolicyOnTick()
+	}
+	require.Equal(t, 1, mpe.EvaluationCount)
+
+	viewData, err := view.RetrieveData("processor/cascading_filter/" + statTraceAgeAtDecisionSec.Name())
+	require.NoError(t, err)
+	require.Len(t, viewData, 1)
+	distribution := viewData[0].Data.(*view.DistributionData)
+	require.EqualValues(t, 1, distribution.Count)
+	require.InDelta(t, decisionWaitSeconds, distribution.Mean, 1)
+}
+
+// testProcessorOption overrides a default of the processor built by newTestProcessor
+type testProcessorOption func(cfsp *cascadingFilterSpanProcessor)
+
+// newTestProcessor builds a processor with manually triggered ticks, where each trace is decided on the second one
+// since its arrival. By default, the sampled traces are dropped and there are no policies.
+func newTestProcessor(t *testing.T, opts ...testProcessorOption) *cascadingFilterSpanProcessor {
+	t.Helper()
+	const maxNumTraces = 100
+	cfsp := &cascadingFilterSpanProcessor{
+		ctx:               context.Background(),
+		nextConsumer:      consumertest.NewTracesNop(),
+		maxNumTraces:      maxNumTraces,
+		logger:            zap.NewNop(),
+		decisionBatcher:   newSyncIDBatcher(1),
+		deleteChan:        make(chan traceKey, maxNumTraces),
+		policyTicker:      &manualTTicker{},
+		maxSpansPerSecond: 10000,
+	}
+	for _, opt := range opts {
+		opt(cfsp)
+	}
+	return cfsp
+}
+
+func withNextConsumer(nextConsumer consumer.TracesConsumer) testProcessorOption {
+	return func(cfsp *cascadingFilterSpanProcessor) {
+		cfsp.nextConsumer = nextConsumer
+	}
+}
+
+func withPolicies(policies ...*Policy) testProcessorOption {
+	return func(cfsp *cascadingFilterSpanProcessor) {
+		cfsp.policies = policies
+	}
+}
+
+func withMaxNumTraces(maxNumTraces uint64) testProcessorOption {
+	return func(cfsp *cascadingFilterSpanProcessor) {
+		cfsp.maxNumTraces = maxNumTraces
+		cfsp.deleteChan = make(chan traceKey, maxNumTraces)
+	}
+}
+
+// withDecisionWait makes the traces decided on the tick after the given number of them since arrival
+func withDecisionWait(numTicks uint64) testProcessorOption {
+	return func(cfsp *cascadingFilterSpanProcessor) {
+		cfsp.decisionBatcher = newSyncIDBatcher(numTicks)
+	}
+}
+
+func withMaxSpansPerSecond(maxSpansPerSecond int64) testProcessorOption {
+	return func(cfsp *cascadingFilterSpanProcessor) {
+		cfsp.maxSpansPerSecond = maxSpansPerSecond
+	}
+}
+
+func tracesWithSpans(traceID pdata.TraceID, numSpans int) pdata.Traces {
+	traces := simpleTracesWithID(traceID)
+	spans := traces.ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans()
+	for i := 1; i < numSpans; i++ {
+		span := pdata.NewSpan()
+		span.SetTraceID(traceID)
+		spans.Append(span)
+	}
+	return traces
+}
+
 func collectSpanIds(trace *pdata.Traces) []pdata.SpanID {
 	spanIDs := make([]pdata.SpanID, 0)
 
@@ -492,8 +1716,97 @@ func (m *mockPolicyEvaluator) Evaluate(_ pdata.TraceID, _ *sampling.TraceData) s
 	return m.NextDecision
 }
 
+// spanCountPolicyEvaluator selects the traces having at least minSpans spans
+type spanCountPolicyEvaluator struct {
+	minSpans int64
+}
+
+var _ sampling.PolicyEvaluator = (*spanCountPolicyEvaluator)(nil)
+
+func (s *spanCountPolicyEvaluator) OnLateArrivingSpans(sampling.Decision, []*pdata.Span) error {
+	return nil
+}
+func (s *spanCountPolicyEvaluator) Evaluate(_ pdata.TraceID, trace *sampling.TraceData) sampling.Decision {
+	if trace.SpanCount >= s.minSpans {
+		return sampling.Sampled
+	}
+	return sampling.NotSampled
+}
+
+// selectingPolicyEvaluator returns the given decision for the selected traces and NotSampled for the others
+type selectingPolicyEvaluator struct {
+	decision sampling.Decision
+	selected map[traceKey]struct{}
+}
+
+var _ sampling.PolicyEvaluator = (*selectingPolicyEvaluator)(nil)
+
+func newSelectingPolicyEvaluator(decision sampling.Decision, traceIDs ...pdata.TraceID) *selectingPolicyEvaluator {
+	selected := make(map[traceKey]struct{})
+	for _, traceID := range traceIDs {
+		selected[traceKey(traceID.Bytes())] = struct{}{}
+	}
+	return &selectingPolicyEvaluator{decision: decision, selected: selected}
+}
+
+func (s *selectingPolicyEvaluator) OnLateArrivingSpans(sampling.Decision, []*pdata.Span) error {
+	return nil
+}
+func (s *selectingPolicyEvaluator) Evaluate(traceID pdata.TraceID, _ *sampling.TraceData) sampling.Decision {
+	if _, ok := s.selected[traceKey(traceID.Bytes())]; ok {
+		return s.decision
+	}
+	return sampling.NotSampled
+}
+
+// budgetPolicyEvaluator samples all traces until its budget is used up, then reports them as rate limited
+type budgetPolicyEvaluator struct {
+	budget           int
+	rateLimited      int64
+	rateLimitedSpans int64
+}
+
+var _ sampling.PolicyEvaluator = (*budgetPolicyEvaluator)(nil)
+var _ sampling.RateLimitReporter = (*budgetPolicyEvaluator)(nil)
+
+func (b *budgetPolicyEvaluator) OnLateArrivingSpans(sampling.Decision, []*pdata.Span) error {
+	return nil
+}
+func (b *budgetPolicyEvaluator) Evaluate(_ pdata.TraceID, trace *sampling.TraceData) sampling.Decision {
+	if b.budget > 0 {
+		b.budget--
+		return sampling.Sampled
+	}
+	b.rateLimited++
+	b.rateLimitedSpans += trace.SpanCount
+	return sampling.NotSampled
+}
+func (b *budgetPolicyEvaluator) EnableRateLimitReporting() {}
+func (b *budgetPolicyEvaluator) RateLimitedCount() int64 {
+	return b.rateLimited
+}
+func (b *budgetPolicyEvaluator) RateLimitedSpanCount() int64 {
+	return b.rateLimitedSpans
+}
+
+// slowPolicyEvaluator simulates an expensive policy
+type slowPolicyEvaluator struct {
+	delay time.Duration
+}
+
+var _ sampling.PolicyEvaluator = (*slowPolicyEvaluator)(nil)
+
+func (s *slowPolicyEvaluator) OnLateArrivingSpans(sampling.Decision, []*pdata.Span) error {
+	return nil
+}
+func (s *slowPolicyEvaluator) Evaluate(pdata.TraceID, *sampling.TraceData) sampling.Decision {
+	time.Sleep(s.delay)
+	return sampling.NotSampled
+}
+
 type manualTTicker struct {
 	Started bool
+	Stopped bool
 }
 
 var _ tTicker = (*manualTTicker)(nil)
@@ -506,6 +1819,7 @@ func (t *manualTTicker) OnTick() {
 }
 
 func (t *manualTTicker) Stop() {
+	t.Stopped = true
 }
 
 type syncIDBatcher struct {
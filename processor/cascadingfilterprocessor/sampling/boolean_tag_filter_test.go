@@ -0,0 +1,94 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func newBooleanAttributeFilter() *policyEvaluator {
+	return &policyEvaluator{
+		logger: zap.NewNop(),
+		booleanAttr: &booleanAttributeFilter{
+			key:   "sampling.force",
+			value: true,
+		},
+		maxSpansPerSecond: math.MaxInt64,
+	}
+}
+
+func TestBooleanTagFilter(t *testing.T) {
+	filter := newBooleanAttributeFilter()
+
+	cases := []struct {
+		Desc          string
+		ResourceAttrs map[string]pdata.AttributeValue
+		SpanAttrs     map[string]pdata.AttributeValue
+		Decision      Decision
+	}{
+		{
+			Desc:      "matching span attribute",
+			SpanAttrs: map[string]pdata.AttributeValue{"sampling.force": pdata.NewAttributeValueBool(true)},
+			Decision:  Sampled,
+		},
+		{
+			Desc:          "matching resource attribute",
+			ResourceAttrs: map[string]pdata.AttributeValue{"sampling.force": pdata.NewAttributeValueBool(true)},
+			Decision:      Sampled,
+		},
+		{
+			Desc:      "nonmatching value",
+			SpanAttrs: map[string]pdata.AttributeValue{"sampling.force": pdata.NewAttributeValueBool(false)},
+			Decision:  NotSampled,
+		},
+		{
+			Desc:      "string value",
+			SpanAttrs: map[string]pdata.AttributeValue{"sampling.force": pdata.NewAttributeValueString("true")},
+			Decision:  NotSampled,
+		},
+		{
+			Desc:      "int value",
+			SpanAttrs: map[string]pdata.AttributeValue{"sampling.force": pdata.NewAttributeValueInt(1)},
+			Decision:  NotSampled,
+		},
+		{
+			Desc:      "missing attribute",
+			SpanAttrs: map[string]pdata.AttributeValue{"other": pdata.NewAttributeValueBool(true)},
+			Decision:  NotSampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), newTraceCrossFieldAttrs(c.ResourceAttrs, c.SpanAttrs))
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestBooleanAttributeRequiresKey(t *testing.T) {
+	_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:                "forced",
+		BooleanAttributeCfg: &config.BooleanAttributeCfg{Value: true},
+	})
+	assert.Error(t, err)
+}
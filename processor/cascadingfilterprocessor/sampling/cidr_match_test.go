@@ -0,0 +1,114 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func newCIDRMatchFilter(t *testing.T) PolicyEvaluator {
+	filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:           "cidr-match",
+		SpansPerSecond: math.MaxInt64,
+		CIDRMatchCfg: &config.CIDRMatchCfg{
+			Key:   "net.host.ip",
+			CIDRs: []string{"10.0.0.0/8", "192.168.1.0/24", "2001:db8::/32"},
+		},
+	})
+	require.NoError(t, err)
+	return filter
+}
+
+func TestCIDRMatchFilter(t *testing.T) {
+	filter := newCIDRMatchFilter(t)
+
+	cases := []struct {
+		Desc          string
+		ResourceAttrs map[string]pdata.AttributeValue
+		SpanAttrs     map[string]pdata.AttributeValue
+		Decision      Decision
+	}{
+		{
+			Desc:          "resource address within range",
+			ResourceAttrs: map[string]pdata.AttributeValue{"net.host.ip": pdata.NewAttributeValueString("10.1.2.3")},
+			Decision:      Sampled,
+		},
+		{
+			Desc:          "span address within range",
+			ResourceAttrs: map[string]pdata.AttributeValue{},
+			SpanAttrs:     map[string]pdata.AttributeValue{"net.host.ip": pdata.NewAttributeValueString("192.168.1.20")},
+			Decision:      Sampled,
+		},
+		{
+			Desc:          "IPv6 address within range",
+			ResourceAttrs: map[string]pdata.AttributeValue{"net.host.ip": pdata.NewAttributeValueString("2001:db8::1")},
+			Decision:      Sampled,
+		},
+		{
+			Desc:          "address outside ranges",
+			ResourceAttrs: map[string]pdata.AttributeValue{"net.host.ip": pdata.NewAttributeValueString("192.168.2.20")},
+			Decision:      NotSampled,
+		},
+		{
+			Desc:          "not an IP address",
+			ResourceAttrs: map[string]pdata.AttributeValue{"net.host.ip": pdata.NewAttributeValueString("localhost")},
+			Decision:      NotSampled,
+		},
+		{
+			Desc:          "non-string value",
+			ResourceAttrs: map[string]pdata.AttributeValue{"net.host.ip": pdata.NewAttributeValueInt(10)},
+			Decision:      NotSampled,
+		},
+		{
+			Desc:          "missing attribute",
+			ResourceAttrs: map[string]pdata.AttributeValue{"host.name": pdata.NewAttributeValueString("10.1.2.3")},
+			Decision:      NotSampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), newTraceCrossFieldAttrs(c.ResourceAttrs, c.SpanAttrs))
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestCIDRMatchValidation(t *testing.T) {
+	cases := []struct {
+		Desc string
+		Cfg  config.CIDRMatchCfg
+	}{
+		{Desc: "missing key", Cfg: config.CIDRMatchCfg{CIDRs: []string{"10.0.0.0/8"}}},
+		{Desc: "missing CIDRs", Cfg: config.CIDRMatchCfg{Key: "net.host.ip"}},
+		{Desc: "invalid CIDR", Cfg: config.CIDRMatchCfg{Key: "net.host.ip", CIDRs: []string{"10.0.0.0"}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			cfg := c.Cfg
+			_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{Name: "cidr-match", CIDRMatchCfg: &cfg})
+			assert.Error(t, err)
+		})
+	}
+}
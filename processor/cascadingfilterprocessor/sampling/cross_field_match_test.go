@@ -0,0 +1,117 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func newCrossFieldMatchFilter() *policyEvaluator {
+	return &policyEvaluator{
+		logger: zap.NewNop(),
+		crossFieldMatch: &crossFieldMatchFilter{
+			resourceKey: "host.name",
+			spanKey:     "http.host",
+		},
+		maxSpansPerSecond: math.MaxInt64,
+	}
+}
+
+func TestCrossFieldMatchFilter(t *testing.T) {
+	filter := newCrossFieldMatchFilter()
+
+	cases := []struct {
+		Desc          string
+		ResourceAttrs map[string]pdata.AttributeValue
+		SpanAttrs     map[string]pdata.AttributeValue
+		Decision      Decision
+	}{
+		{
+			Desc:          "matching string values",
+			ResourceAttrs: map[string]pdata.AttributeValue{"host.name": pdata.NewAttributeValueString("foo")},
+			SpanAttrs:     map[string]pdata.AttributeValue{"http.host": pdata.NewAttributeValueString("foo")},
+			Decision:      Sampled,
+		},
+		{
+			Desc:          "matching int values",
+			ResourceAttrs: map[string]pdata.AttributeValue{"host.name": pdata.NewAttributeValueInt(8)},
+			SpanAttrs:     map[string]pdata.AttributeValue{"http.host": pdata.NewAttributeValueInt(8)},
+			Decision:      Sampled,
+		},
+		{
+			Desc:          "nonmatching values",
+			ResourceAttrs: map[string]pdata.AttributeValue{"host.name": pdata.NewAttributeValueString("foo")},
+			SpanAttrs:     map[string]pdata.AttributeValue{"http.host": pdata.NewAttributeValueString("bar")},
+			Decision:      NotSampled,
+		},
+		{
+			Desc:          "mismatching types",
+			ResourceAttrs: map[string]pdata.AttributeValue{"host.name": pdata.NewAttributeValueString("8")},
+			SpanAttrs:     map[string]pdata.AttributeValue{"http.host": pdata.NewAttributeValueInt(8)},
+			Decision:      NotSampled,
+		},
+		{
+			Desc:          "missing resource attribute",
+			ResourceAttrs: map[string]pdata.AttributeValue{},
+			SpanAttrs:     map[string]pdata.AttributeValue{"http.host": pdata.NewAttributeValueString("foo")},
+			Decision:      NotSampled,
+		},
+		{
+			Desc:          "missing span attribute",
+			ResourceAttrs: map[string]pdata.AttributeValue{"host.name": pdata.NewAttributeValueString("foo")},
+			SpanAttrs:     map[string]pdata.AttributeValue{"host.name": pdata.NewAttributeValueString("foo")},
+			Decision:      NotSampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), newTraceCrossFieldAttrs(c.ResourceAttrs, c.SpanAttrs))
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestCrossFieldMatchRequiresBothKeys(t *testing.T) {
+	_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:               "cross-field",
+		CrossFieldMatchCfg: &config.CrossFieldMatchCfg{ResourceKey: "host.name"},
+	})
+	assert.Error(t, err)
+}
+
+func newTraceCrossFieldAttrs(resourceAttrs map[string]pdata.AttributeValue, spanAttrs map[string]pdata.AttributeValue) *TraceData {
+	traces := pdata.NewTraces()
+	traces.ResourceSpans().Resize(1)
+	rs := traces.ResourceSpans().At(0)
+	rs.Resource().Attributes().InitFromMap(resourceAttrs)
+	rs.InstrumentationLibrarySpans().Resize(1)
+	ils := rs.InstrumentationLibrarySpans().At(0)
+	ils.Spans().Resize(1)
+	span := ils.Spans().At(0)
+	span.SetTraceID(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+	span.SetSpanID(pdata.NewSpanID([8]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+	span.Attributes().InitFromMap(spanAttrs)
+	return &TraceData{
+		ReceivedBatches: []pdata.Traces{traces},
+	}
+}
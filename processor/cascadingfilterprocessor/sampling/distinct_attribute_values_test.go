@@ -0,0 +1,208 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func newDistinctAttributeValuesFilter(minValues int) *policyEvaluator {
+	return &policyEvaluator{
+		logger: zap.NewNop(),
+		distinctAttrs: &distinctAttributeValuesFilter{
+			key:       "db.statement",
+			minValues: minValues,
+		},
+		maxSpansPerSecond: math.MaxInt64,
+	}
+}
+
+func TestDistinctAttributeValuesFilter(t *testing.T) {
+	cases := []struct {
+		Desc      string
+		MinValues int
+		Values    []pdata.AttributeValue
+		Decision  Decision
+	}{
+		{
+			Desc:      "below threshold",
+			MinValues: 3,
+			Values: []pdata.AttributeValue{
+				pdata.NewAttributeValueString("SELECT 1"),
+				pdata.NewAttributeValueString("SELECT 2"),
+			},
+			Decision: NotSampled,
+		},
+		{
+			Desc:      "at threshold",
+			MinValues: 3,
+			Values: []pdata.AttributeValue{
+				pdata.NewAttributeValueString("SELECT 1"),
+				pdata.NewAttributeValueString("SELECT 2"),
+				pdata.NewAttributeValueString("SELECT 3"),
+			},
+			Decision: Sampled,
+		},
+		{
+			Desc:      "repeated values are counted once",
+			MinValues: 3,
+			Values: []pdata.AttributeValue{
+				pdata.NewAttributeValueString("SELECT 1"),
+				pdata.NewAttributeValueString("SELECT 2"),
+				pdata.NewAttributeValueString("SELECT 2"),
+			},
+			Decision: NotSampled,
+		},
+		{
+			Desc:      "values of different types are distinct",
+			MinValues: 2,
+			Values: []pdata.AttributeValue{
+				pdata.NewAttributeValueString("1"),
+				pdata.NewAttributeValueInt(1),
+			},
+			Decision: Sampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			filter := newDistinctAttributeValuesFilter(c.MinValues)
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), newTraceWithAttributeValues("db.statement", c.Values))
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestDistinctAttributeValuesCounterIsBounded(t *testing.T) {
+	counter := newDistinctValuesCounter(&distinctAttributeValuesFilter{key: "db.statement", minValues: 2})
+	for i := 0; i < 10; i++ {
+		attrs := pdata.NewAttributeMap()
+		attrs.UpsertInt("db.statement", int64(i))
+		counter.add(attrs)
+	}
+
+	assert.True(t, counter.thresholdReached())
+	assert.Len(t, counter.values, 2)
+}
+
+func TestDistinctAttributeValuesConfigValidation(t *testing.T) {
+	_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:                          "distinct-values",
+		MinDistinctAttributeValuesCfg: &config.MinDistinctAttributeValuesCfg{Key: "db.statement"},
+	})
+	assert.Error(t, err)
+
+	_, err = NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:                          "distinct-values",
+		MinDistinctAttributeValuesCfg: &config.MinDistinctAttributeValuesCfg{MinValues: 2},
+	})
+	assert.Error(t, err)
+}
+
+func TestTenantBoundaryCrossing(t *testing.T) {
+	filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:                          "multi-tenant",
+		SpansPerSecond:                math.MaxInt64,
+		MinDistinctAttributeValuesCfg: &config.MinDistinctAttributeValuesCfg{Key: "tenant.id", MinValues: 2},
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		Desc     string
+		Trace    *TraceData
+		Decision Decision
+	}{
+		{
+			Desc: "single tenant spans",
+			Trace: newTraceWithAttributeValues("tenant.id", []pdata.AttributeValue{
+				pdata.NewAttributeValueString("tenant-a"),
+				pdata.NewAttributeValueString("tenant-a"),
+			}),
+			Decision: NotSampled,
+		},
+		{
+			Desc: "multi tenant spans",
+			Trace: newTraceWithAttributeValues("tenant.id", []pdata.AttributeValue{
+				pdata.NewAttributeValueString("tenant-a"),
+				pdata.NewAttributeValueString("tenant-b"),
+			}),
+			Decision: Sampled,
+		},
+		{
+			Desc:     "single tenant resources",
+			Trace:    newTraceWithResourceAttributeValues("tenant.id", []string{"tenant-a", "tenant-a"}),
+			Decision: NotSampled,
+		},
+		{
+			Desc:     "multi tenant resources",
+			Trace:    newTraceWithResourceAttributeValues("tenant.id", []string{"tenant-a", "tenant-b"}),
+			Decision: Sampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), c.Trace)
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+// newTraceWithResourceAttributeValues creates a trace with a single span for each of the resources having given values
+func newTraceWithResourceAttributeValues(key string, values []string) *TraceData {
+	traces := pdata.NewTraces()
+	traces.ResourceSpans().Resize(len(values))
+	for i, value := range values {
+		rs := traces.ResourceSpans().At(i)
+		rs.Resource().Attributes().UpsertString(key, value)
+		rs.InstrumentationLibrarySpans().Resize(1)
+		ils := rs.InstrumentationLibrarySpans().At(0)
+		ils.Spans().Resize(1)
+		span := ils.Spans().At(0)
+		span.SetTraceID(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+		span.SetSpanID(pdata.NewSpanID([8]byte{byte(i + 1)}))
+	}
+	return &TraceData{
+		ReceivedBatches: []pdata.Traces{traces},
+		SpanCount:       int64(len(values)),
+	}
+}
+
+func newTraceWithAttributeValues(key string, values []pdata.AttributeValue) *TraceData {
+	traces := pdata.NewTraces()
+	traces.ResourceSpans().Resize(1)
+	rs := traces.ResourceSpans().At(0)
+	rs.InstrumentationLibrarySpans().Resize(1)
+	ils := rs.InstrumentationLibrarySpans().At(0)
+	ils.Spans().Resize(len(values))
+	for i, value := range values {
+		span := ils.Spans().At(i)
+		span.SetTraceID(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+		span.SetSpanID(pdata.NewSpanID([8]byte{byte(i + 1)}))
+		span.Attributes().Upsert(key, value)
+	}
+	return &TraceData{
+		ReceivedBatches: []pdata.Traces{traces},
+		SpanCount:       int64(len(values)),
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func TestDropPolicy(t *testing.T) {
+	traceID := pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	empty := map[string]pdata.AttributeValue{}
+
+	cases := []struct {
+		Desc        string
+		InvertMatch bool
+		SpanValue   string
+		Decision    Decision
+	}{
+		{
+			Desc:      "matching trace",
+			SpanValue: "synthetic",
+			Decision:  Drop,
+		},
+		{
+			Desc:      "nonmatching trace",
+			SpanValue: "user",
+			Decision:  NotSampled,
+		},
+		{
+			Desc:        "inverted match",
+			InvertMatch: true,
+			SpanValue:   "user",
+			Decision:    Drop,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			// The budget is not consulted, so the traces are dropped even without spans_per_second
+			filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+				Name:               "drop-synthetic",
+				StringAttributeCfg: &config.StringAttributeCfg{Key: "traffic.type", Values: []string{"synthetic"}},
+				InvertMatch:        c.InvertMatch,
+				Drop:               true,
+			})
+			require.NoError(t, err)
+
+			trace := newTraceStringAttrs(empty, "traffic.type", c.SpanValue)
+			trace.SpanCount = 10
+			assert.Equal(t, c.Decision, filter.Evaluate(traceID, trace))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func newEstimatedSizeFilter(minEstimatedSize *int64, maxEstimatedSize *int64) *policyEvaluator {
+	return &policyEvaluator{
+		logger:            zap.NewNop(),
+		minEstimatedSize:  minEstimatedSize,
+		maxEstimatedSize:  maxEstimatedSize,
+		maxSpansPerSecond: math.MaxInt64,
+	}
+}
+
+func TestEstimatedSizeFilter(t *testing.T) {
+	traceID := pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	threshold := int64(500)
+
+	// The estimated size of the small trace is 2 bytes, while the large one has over 1000 bytes
+	smallTrace := newTraceStringAttrs(map[string]pdata.AttributeValue{}, "k", "v")
+	largeTrace := newTraceStringAttrs(
+		map[string]pdata.AttributeValue{"service.name": pdata.NewAttributeValueString("checkout")},
+		"db.statement", strings.Repeat("x", 1000))
+
+	cases := []struct {
+		Desc          string
+		Filter        *policyEvaluator
+		SmallDecision Decision
+		LargeDecision Decision
+	}{
+		{
+			Desc:          "above threshold",
+			Filter:        newEstimatedSizeFilter(&threshold, nil),
+			SmallDecision: NotSampled,
+			LargeDecision: Sampled,
+		},
+		{
+			Desc:          "below threshold",
+			Filter:        newEstimatedSizeFilter(nil, &threshold),
+			SmallDecision: Sampled,
+			LargeDecision: NotSampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			assert.Equal(t, c.SmallDecision, c.Filter.Evaluate(traceID, smallTrace))
+			assert.Equal(t, c.LargeDecision, c.Filter.Evaluate(traceID, largeTrace))
+		})
+	}
+}
+
+func TestEstimatedAttributesSize(t *testing.T) {
+	attrs := pdata.NewAttributeMap()
+	attrs.UpsertString("http.method", "GET")
+	attrs.UpsertInt("http.status_code", 200)
+	attrs.UpsertBool("error", false)
+
+	// Keys and string values are counted by their length, other values are estimated
+	expected := int64(len("http.method") + len("GET") + len("http.status_code") + len("error") + 2*nonStringValueSize)
+	assert.Equal(t, expected, estimatedAttributesSize(attrs))
+}
+
+func TestEstimatedSizeValidation(t *testing.T) {
+	negative := int64(-1)
+	small := int64(100)
+	large := int64(1000)
+
+	cases := []struct {
+		Desc string
+		Cfg  config.PropertiesCfg
+	}{
+		{Desc: "negative minimum", Cfg: config.PropertiesCfg{MinEstimatedSize: &negative}},
+		{Desc: "negative maximum", Cfg: config.PropertiesCfg{MaxEstimatedSize: &negative}},
+		{Desc: "minimum exceeding maximum", Cfg: config.PropertiesCfg{MinEstimatedSize: &large, MaxEstimatedSize: &small}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{Name: "estimated-size", SpansPerSecond: 100, PropertiesCfg: c.Cfg})
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func newNetworkLatencyFilter(minNetworkLatency time.Duration) *policyEvaluator {
+	return &policyEvaluator{
+		logger:            zap.NewNop(),
+		minNetworkLatency: &minNetworkLatency,
+		maxSpansPerSecond: math.MaxInt64,
+	}
+}
+
+func TestNetworkLatencyFilter(t *testing.T) {
+	traceID := pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+
+	cases := []struct {
+		Desc     string
+		Spans    []testSpan
+		Decision Decision
+	}{
+		{
+			Desc: "large gap",
+			Spans: []testSpan{
+				{id: 1, kind: pdata.SpanKindSERVER, duration: 800 * time.Millisecond},
+				{id: 2, parentID: 1, kind: pdata.SpanKindCLIENT, duration: 700 * time.Millisecond},
+				{id: 3, parentID: 2, kind: pdata.SpanKindSERVER, duration: 200 * time.Millisecond},
+			},
+			Decision: Sampled,
+		},
+		{
+			Desc: "small gap",
+			Spans: []testSpan{
+				{id: 1, kind: pdata.SpanKindSERVER, duration: 800 * time.Millisecond},
+				{id: 2, parentID: 1, kind: pdata.SpanKindCLIENT, duration: 700 * time.Millisecond},
+				{id: 3, parentID: 2, kind: pdata.SpanKindSERVER, duration: 650 * time.Millisecond},
+			},
+			Decision: NotSampled,
+		},
+		{
+			Desc: "one of the pairs having large gap",
+			Spans: []testSpan{
+				{id: 1, kind: pdata.SpanKindSERVER, duration: time.Second},
+				{id: 2, parentID: 1, kind: pdata.SpanKindCLIENT, duration: 300 * time.Millisecond},
+				{id: 3, parentID: 2, kind: pdata.SpanKindSERVER, duration: 290 * time.Millisecond},
+				{id: 4, parentID: 1, kind: pdata.SpanKindCLIENT, duration: 600 * time.Millisecond},
+				{id: 5, parentID: 4, kind: pdata.SpanKindSERVER, duration: 100 * time.Millisecond},
+			},
+			Decision: Sampled,
+		},
+		{
+			Desc: "server span not received",
+			Spans: []testSpan{
+				{id: 1, kind: pdata.SpanKindSERVER, duration: 800 * time.Millisecond},
+				{id: 2, parentID: 1, kind: pdata.SpanKindCLIENT, duration: 700 * time.Millisecond},
+			},
+			Decision: NotSampled,
+		},
+		{
+			Desc: "parent of the server span is not a client span",
+			Spans: []testSpan{
+				{id: 1, kind: pdata.SpanKindINTERNAL, duration: 800 * time.Millisecond},
+				{id: 2, parentID: 1, kind: pdata.SpanKindSERVER, duration: 100 * time.Millisecond},
+			},
+			Decision: NotSampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			trace := newTraceWithSpans(c.Spans)
+			assert.Equal(t, c.Decision, newNetworkLatencyFilter(100*time.Millisecond).Evaluate(traceID, trace))
+		})
+	}
+}
+
+func TestNetworkLatencyFilterValidation(t *testing.T) {
+	minNetworkLatency := time.Duration(0)
+	_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:           "network-latency",
+		SpansPerSecond: 100,
+		PropertiesCfg:  config.PropertiesCfg{MinNetworkLatency: &minNetworkLatency},
+	})
+	require.Error(t, err)
+}
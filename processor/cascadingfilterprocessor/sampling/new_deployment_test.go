@@ -0,0 +1,130 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func newNewDeploymentFilter(t *testing.T, cfg config.NewDeploymentCfg, now *time.Time) *policyEvaluator {
+	filter, err := createNewDeploymentFilter(&cfg)
+	require.NoError(t, err)
+	filter.now = func() time.Time { return *now }
+	return &policyEvaluator{
+		logger:            zap.NewNop(),
+		newDeployment:     filter,
+		maxSpansPerSecond: math.MaxInt64,
+	}
+}
+
+func newTraceWithDeployment(version string) *TraceData {
+	trace := newTraceStringAttrs(map[string]pdata.AttributeValue{"service.version": pdata.NewAttributeValueString(version)}, "example", "value")
+	trace.SpanCount = 1
+	return trace
+}
+
+func TestNewDeploymentFilterMaxTraces(t *testing.T) {
+	now := time.Unix(1000, 0)
+	filter := newNewDeploymentFilter(t, config.NewDeploymentCfg{Key: "service.version", MaxTraces: 3}, &now)
+
+	// The first traces of the deployment are all selected, then it's no longer new
+	for i := 1; i <= 3; i++ {
+		assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(i), newTraceWithDeployment("v1")))
+	}
+	assert.Equal(t, NotSampled, filter.Evaluate(deploymentTraceID(4), newTraceWithDeployment("v1")))
+
+	// Another deployment is new on its own
+	assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(5), newTraceWithDeployment("v2")))
+	assert.Equal(t, NotSampled, filter.Evaluate(deploymentTraceID(6), newTraceWithDeployment("v1")))
+
+	// Traces without the deployment attribute are not selected
+	assert.Equal(t, NotSampled, filter.Evaluate(deploymentTraceID(7), newTraceStringAttrs(map[string]pdata.AttributeValue{}, "example", "value")))
+}
+
+func TestNewDeploymentFilterCountsTracesOnce(t *testing.T) {
+	now := time.Unix(1000, 0)
+	filter := newNewDeploymentFilter(t, config.NewDeploymentCfg{Key: "service.version", MaxTraces: 2}, &now)
+
+	// Evaluating the same trace again (e.g. by sub-policies or after exceeding the limits) does not use up the limit
+	trace := newTraceWithDeployment("v1")
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(1), trace))
+	}
+	assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(2), newTraceWithDeployment("v1")))
+	assert.Equal(t, NotSampled, filter.Evaluate(deploymentTraceID(3), newTraceWithDeployment("v1")))
+
+	// The traces counted within the limit remain selected, the ones over it do not
+	assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(1), trace))
+	assert.Equal(t, NotSampled, filter.Evaluate(deploymentTraceID(3), newTraceWithDeployment("v1")))
+}
+
+func TestNewDeploymentFilterDuration(t *testing.T) {
+	now := time.Unix(1000, 0)
+	filter := newNewDeploymentFilter(t, config.NewDeploymentCfg{Key: "service.version", Duration: time.Minute}, &now)
+
+	assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(1), newTraceWithDeployment("v1")))
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(2), newTraceWithDeployment("v1")))
+	assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(3), newTraceWithDeployment("v2")))
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, NotSampled, filter.Evaluate(deploymentTraceID(4), newTraceWithDeployment("v1")))
+	assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(5), newTraceWithDeployment("v2")))
+}
+
+func TestNewDeploymentFilterMaxDeployments(t *testing.T) {
+	now := time.Unix(1000, 0)
+	filter := newNewDeploymentFilter(t, config.NewDeploymentCfg{Key: "service.version", MaxTraces: 1, MaxDeployments: 2}, &now)
+
+	assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(1), newTraceWithDeployment("v1")))
+	assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(2), newTraceWithDeployment("v2")))
+	assert.Equal(t, NotSampled, filter.Evaluate(deploymentTraceID(3), newTraceWithDeployment("v1")))
+
+	// The earliest seen deployment is forgotten, so it's considered new again
+	assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(4), newTraceWithDeployment("v3")))
+	assert.Len(t, filter.newDeployment.deployments, 2)
+	assert.Equal(t, Sampled, filter.Evaluate(deploymentTraceID(5), newTraceWithDeployment("v1")))
+}
+
+func TestNewDeploymentFilterValidation(t *testing.T) {
+	cases := []struct {
+		Desc string
+		Cfg  config.NewDeploymentCfg
+	}{
+		{Desc: "missing key", Cfg: config.NewDeploymentCfg{MaxTraces: 10}},
+		{Desc: "missing limits", Cfg: config.NewDeploymentCfg{Key: "service.version"}},
+		{Desc: "negative max traces", Cfg: config.NewDeploymentCfg{Key: "service.version", MaxTraces: -1}},
+		{Desc: "negative max deployments", Cfg: config.NewDeploymentCfg{Key: "service.version", MaxTraces: 10, MaxDeployments: -1}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			_, err := createNewDeploymentFilter(&c.Cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func deploymentTraceID(i int) pdata.TraceID {
+	return pdata.NewTraceID([16]byte{byte(i)})
+}
@@ -0,0 +1,135 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func newNumericComparisonFilter(t *testing.T, operator string) *policyEvaluator {
+	filter, err := createNumericComparisonFilter(&config.NumericComparisonCfg{
+		FirstKey:  "retry_count",
+		SecondKey: "max_retries",
+		Operator:  operator,
+	})
+	require.NoError(t, err)
+
+	return &policyEvaluator{
+		logger:            zap.NewNop(),
+		numericComparison: filter,
+		maxSpansPerSecond: math.MaxInt64,
+	}
+}
+
+func TestNumericComparisonFilter(t *testing.T) {
+	cases := []struct {
+		Desc      string
+		Operator  string
+		SpanAttrs map[string]pdata.AttributeValue
+		Decision  Decision
+	}{
+		{
+			Desc:      "gt matching",
+			Operator:  "gt",
+			SpanAttrs: map[string]pdata.AttributeValue{"retry_count": pdata.NewAttributeValueInt(4), "max_retries": pdata.NewAttributeValueInt(3)},
+			Decision:  Sampled,
+		},
+		{
+			Desc:      "gt equal values",
+			Operator:  "gt",
+			SpanAttrs: map[string]pdata.AttributeValue{"retry_count": pdata.NewAttributeValueInt(3), "max_retries": pdata.NewAttributeValueInt(3)},
+			Decision:  NotSampled,
+		},
+		{
+			Desc:      "lt matching",
+			Operator:  "lt",
+			SpanAttrs: map[string]pdata.AttributeValue{"retry_count": pdata.NewAttributeValueInt(1), "max_retries": pdata.NewAttributeValueInt(3)},
+			Decision:  Sampled,
+		},
+		{
+			Desc:      "lt nonmatching",
+			Operator:  "lt",
+			SpanAttrs: map[string]pdata.AttributeValue{"retry_count": pdata.NewAttributeValueInt(5), "max_retries": pdata.NewAttributeValueInt(3)},
+			Decision:  NotSampled,
+		},
+		{
+			Desc:      "eq matching int and double",
+			Operator:  "eq",
+			SpanAttrs: map[string]pdata.AttributeValue{"retry_count": pdata.NewAttributeValueInt(3), "max_retries": pdata.NewAttributeValueDouble(3.0)},
+			Decision:  Sampled,
+		},
+		{
+			Desc:      "eq nonmatching",
+			Operator:  "eq",
+			SpanAttrs: map[string]pdata.AttributeValue{"retry_count": pdata.NewAttributeValueInt(2), "max_retries": pdata.NewAttributeValueInt(3)},
+			Decision:  NotSampled,
+		},
+		{
+			Desc:      "missing attribute",
+			Operator:  "gt",
+			SpanAttrs: map[string]pdata.AttributeValue{"retry_count": pdata.NewAttributeValueInt(4)},
+			Decision:  NotSampled,
+		},
+		{
+			Desc:      "non-numeric attribute",
+			Operator:  "gt",
+			SpanAttrs: map[string]pdata.AttributeValue{"retry_count": pdata.NewAttributeValueString("4"), "max_retries": pdata.NewAttributeValueInt(3)},
+			Decision:  NotSampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			filter := newNumericComparisonFilter(t, c.Operator)
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), newTraceCrossFieldAttrs(map[string]pdata.AttributeValue{}, c.SpanAttrs))
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestNumericComparisonConfigValidation(t *testing.T) {
+	cases := []struct {
+		Desc string
+		Cfg  config.NumericComparisonCfg
+	}{
+		{
+			Desc: "missing second key",
+			Cfg:  config.NumericComparisonCfg{FirstKey: "retry_count", Operator: "gt"},
+		},
+		{
+			Desc: "unknown operator",
+			Cfg:  config.NumericComparisonCfg{FirstKey: "retry_count", SecondKey: "max_retries", Operator: "ge"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			cfg := c.Cfg
+			_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+				Name:                 "numeric-comparison",
+				NumericComparisonCfg: &cfg,
+			})
+			assert.Error(t, err)
+		})
+	}
+}
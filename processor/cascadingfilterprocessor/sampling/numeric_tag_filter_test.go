@@ -22,6 +22,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go.opentelemetry.io/collector/consumer/pdata"
 	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
 )
 
 func newNumericAttributeFilter(minValue int64, maxValue int64) *policyEvaluator {
@@ -84,6 +86,96 @@ func TestNumericTagFilter(t *testing.T) {
 	}
 }
 
+func TestNumericTagFilterDoubleValues(t *testing.T) {
+	var empty = map[string]pdata.AttributeValue{}
+	minDoubleValue := 0.25
+	maxDoubleValue := 0.75
+
+	cases := []struct {
+		Desc     string
+		Cfg      config.NumericAttributeCfg
+		Trace    *TraceData
+		Decision Decision
+	}{
+		{
+			Desc:     "double attribute within int bounds",
+			Cfg:      config.NumericAttributeCfg{Key: "example", MinValue: 1, MaxValue: 10},
+			Trace:    newTraceDoubleAttrs(empty, "example", 2.5),
+			Decision: Sampled,
+		},
+		{
+			Desc:     "double attribute outside of int bounds",
+			Cfg:      config.NumericAttributeCfg{Key: "example", MinValue: 1, MaxValue: 10},
+			Trace:    newTraceDoubleAttrs(empty, "example", 10.5),
+			Decision: NotSampled,
+		},
+		{
+			Desc:     "double attribute within double bounds",
+			Cfg:      config.NumericAttributeCfg{Key: "example", MinDoubleValue: &minDoubleValue, MaxDoubleValue: &maxDoubleValue},
+			Trace:    newTraceDoubleAttrs(empty, "example", 0.5),
+			Decision: Sampled,
+		},
+		{
+			Desc:     "double attribute at lower double bound",
+			Cfg:      config.NumericAttributeCfg{Key: "example", MinDoubleValue: &minDoubleValue, MaxDoubleValue: &maxDoubleValue},
+			Trace:    newTraceDoubleAttrs(empty, "example", 0.25),
+			Decision: Sampled,
+		},
+		{
+			Desc:     "double attribute outside of double bounds",
+			Cfg:      config.NumericAttributeCfg{Key: "example", MinDoubleValue: &minDoubleValue, MaxDoubleValue: &maxDoubleValue},
+			Trace:    newTraceDoubleAttrs(empty, "example", 0.8),
+			Decision: NotSampled,
+		},
+		{
+			Desc:     "int attribute coerced to double bounds",
+			Cfg:      config.NumericAttributeCfg{Key: "example", MinDoubleValue: &minDoubleValue, MaxDoubleValue: &maxDoubleValue},
+			Trace:    newTraceIntAttrs(empty, "example", 1),
+			Decision: NotSampled,
+		},
+		{
+			Desc:     "int attribute ignores int bounds when double bounds are set",
+			Cfg:      config.NumericAttributeCfg{Key: "example", MinValue: 0, MaxValue: 10, MinDoubleValue: &minDoubleValue, MaxDoubleValue: &maxDoubleValue},
+			Trace:    newTraceIntAttrs(empty, "example", 5),
+			Decision: NotSampled,
+		},
+		{
+			Desc:     "string attribute is not numeric",
+			Cfg:      config.NumericAttributeCfg{Key: "example", MinValue: -10, MaxValue: 10},
+			Trace:    newTraceStringAttrs(empty, "example", "0"),
+			Decision: NotSampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			cfg := c.Cfg
+			filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{Name: "numeric", SpansPerSecond: math.MaxInt32, NumericAttributeCfg: &cfg})
+			assert.NoError(t, err)
+			c.Trace.SpanCount = 1
+			u, _ := uuid.NewRandom()
+			assert.Equal(t, c.Decision, filter.Evaluate(pdata.NewTraceID(u), c.Trace))
+		})
+	}
+}
+
+func TestNumericTagFilterInvalidDoubleBounds(t *testing.T) {
+	minDoubleValue := 0.25
+	nan := math.NaN()
+
+	_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:                "numeric",
+		NumericAttributeCfg: &config.NumericAttributeCfg{Key: "example", MinDoubleValue: &minDoubleValue},
+	})
+	assert.Error(t, err)
+
+	_, err = NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:                "numeric",
+		NumericAttributeCfg: &config.NumericAttributeCfg{Key: "example", MinDoubleValue: &minDoubleValue, MaxDoubleValue: &nan},
+	})
+	assert.Error(t, err)
+}
+
 func TestOnLateArrivingSpans_NumericTagFilter(t *testing.T) {
 	filter := newNumericAttributeFilter(math.MinInt32, math.MaxInt32)
 	err := filter.OnLateArrivingSpans(NotSampled, nil)
@@ -110,3 +202,10 @@ func newTraceIntAttrs(nodeAttrs map[string]pdata.AttributeValue, spanAttrKey str
 		ReceivedBatches: traceBatches,
 	}
 }
+
+func newTraceDoubleAttrs(nodeAttrs map[string]pdata.AttributeValue, spanAttrKey string, spanAttrValue float64) *TraceData {
+	trace := newTraceIntAttrs(nodeAttrs, spanAttrKey, 0)
+	span := trace.ReceivedBatches[0].ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans().At(0)
+	span.Attributes().UpsertDouble(spanAttrKey, spanAttrValue)
+	return trace
+}
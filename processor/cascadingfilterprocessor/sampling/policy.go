@@ -30,6 +30,13 @@ type TraceData struct {
 	FinalDecision Decision
 	// SelectedByProbabilisticFilter determines if this trace was selected by probabilistic filter
 	SelectedByProbabilisticFilter bool
+	// UpstreamSamplingProbability is the probability the trace was already sampled with before its arrival (e.g. by
+	// head-based sampling), as seen by the probabilistic filter. It's zero when unknown
+	UpstreamSamplingProbability float64
+	// ExpeditedDecision determines if the trace was scheduled for a decision before the regular decision wait
+	ExpeditedDecision bool
+	// FastTracked determines if the trace was sampled as soon as its spans matched the fast-track condition
+	FastTracked bool
 	// Arrival time the first span for the trace was received.
 	ArrivalTime time.Time
 	// Decisiontime time when sampling decision was taken.
@@ -60,8 +67,33 @@ const (
 	// Dropped is used when data needs to be purged before the sampling policy
 	// had a chance to evaluate it.
 	Dropped
+	// Drop is used to indicate that the trace must not be sampled, regardless
+	// of the decisions of other policies.
+	Drop
 )
 
+// String returns the name of the decision.
+func (d Decision) String() string {
+	switch d {
+	case Unspecified:
+		return "Unspecified"
+	case Pending:
+		return "Pending"
+	case Sampled:
+		return "Sampled"
+	case SecondChance:
+		return "SecondChance"
+	case NotSampled:
+		return "NotSampled"
+	case Dropped:
+		return "Dropped"
+	case Drop:
+		return "Drop"
+	default:
+		return "Unknown"
+	}
+}
+
 // PolicyEvaluator implements a cascading policy evaluator,
 // which makes a sampling decision for a given trace when requested.
 type PolicyEvaluator interface {
@@ -74,3 +106,16 @@ type PolicyEvaluator interface {
 	// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
 	Evaluate(traceID pdata.TraceID, trace *TraceData) Decision
 }
+
+// RateLimitReporter is implemented by the policy evaluators which have their own rate limit, so it's possible
+// to tell how many of the matching traces were not sampled because of it.
+type RateLimitReporter interface {
+	// EnableRateLimitReporting makes the evaluator check its criteria also for the traces exceeding its rate limit,
+	// so the matching ones are counted. It's disabled by default, as it's costly for overloaded policies.
+	EnableRateLimitReporting()
+	// RateLimitedCount returns the number of traces which matched the policy, but were not sampled as they
+	// exceeded its rate limit, since the evaluator was created.
+	RateLimitedCount() int64
+	// RateLimitedSpanCount returns the number of spans of such traces, since the evaluator was created.
+	RateLimitedSpanCount() int64
+}
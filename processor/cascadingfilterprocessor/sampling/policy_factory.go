@@ -16,9 +16,15 @@ package sampling
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
 	"regexp"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/collector/consumer/pdata"
 	"go.uber.org/zap"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
@@ -27,47 +33,174 @@ import (
 type numericAttributeFilter struct {
 	key                string
 	minValue, maxValue int64
+	// doubleBounds makes both int and double attributes compared against minDoubleValue and maxDoubleValue
+	doubleBounds                   bool
+	minDoubleValue, maxDoubleValue float64
 }
 
 type stringAttributeFilter struct {
-	key    string
-	values map[string]struct{}
+	key         string
+	values      map[string]struct{}
+	patterns    []*regexp.Regexp
+	invertMatch bool
+}
+
+type booleanAttributeFilter struct {
+	key   string
+	value bool
+}
+
+type crossFieldMatchFilter struct {
+	resourceKey string
+	spanKey     string
+}
+
+// comparisonOperator describes how the values of numeric comparison filter are compared
+type comparisonOperator int
+
+const (
+	greaterThan comparisonOperator = iota
+	lessThan
+	equalTo
+)
+
+type numericComparisonFilter struct {
+	firstKey  string
+	secondKey string
+	operator  comparisonOperator
+}
+
+type distinctAttributeValuesFilter struct {
+	key       string
+	minValues int
+}
+
+type errorFilter struct {
+	childErrorOnly bool
+}
+
+type statusCodeFilter struct {
+	statusCodes map[pdata.StatusCode]struct{}
+}
+
+// newDeploymentFilter tracks the deployments seen, it's shared by all evaluations of the policy
+type newDeploymentFilter struct {
+	key            string
+	maxTraces      int64
+	duration       time.Duration
+	maxDeployments int
+	// now returns the current time, it's replaced in tests
+	now func() time.Time
+
+	// lock guards the tracked deployments, as the evaluator might be called concurrently
+	lock        sync.Mutex
+	deployments map[string]*deploymentSighting
+	// order holds the tracked deployments from the earliest seen, so they are forgotten in that order
+	order []string
+}
+
+type deploymentSighting struct {
+	firstSeen time.Time
+	// traceIDs holds the first MaxTraces traces of the deployment, so each of them is counted once, however many
+	// times it's evaluated (e.g. by sub-policies or when it's checked again after exceeding the limits)
+	traceIDs map[[16]byte]struct{}
+}
+
+type cidrMatchFilter struct {
+	key      string
+	networks []*net.IPNet
+}
+
+// subPoliciesFilter combines the criteria of the sub-policies with the operator
+type subPoliciesFilter struct {
+	operator   string
+	evaluators []*policyEvaluator
 }
 
 type policyEvaluator struct {
-	numericAttr *numericAttributeFilter
-	stringAttr  *stringAttributeFilter
+	numericAttr       *numericAttributeFilter
+	stringAttr        *stringAttributeFilter
+	resourceAttr      *stringAttributeFilter
+	booleanAttr       *booleanAttributeFilter
+	crossFieldMatch   *crossFieldMatchFilter
+	numericComparison *numericComparisonFilter
+	distinctAttrs     *distinctAttributeValuesFilter
+	spanError         *errorFilter
+	cidrMatch         *cidrMatchFilter
+	statusCode        *statusCodeFilter
+	newDeployment     *newDeploymentFilter
+	subPolicies       *subPoliciesFilter
 
-	operationRe      *regexp.Regexp
-	minDuration      *time.Duration
-	minNumberOfSpans *int
+	operationRe       *regexp.Regexp
+	minDuration       *time.Duration
+	minNumberOfSpans  *int
+	minEstimatedSize  *int64
+	maxEstimatedSize  *int64
+	minErrorRatio     *float64
+	minNumberOfErrors *int
+	remoteParent      *bool
+	spanKinds         map[pdata.SpanKind]struct{}
+	minNetworkLatency *time.Duration
 
+	// rateLock guards the rate state and random, as the evaluator might be called concurrently
+	rateLock             sync.Mutex
 	currentSecond        int64
 	maxSpansPerSecond    int64
 	spansInCurrentSecond int64
+	// rateLimitedCount is the number of matching traces exceeding the rate limit, it's updated atomically
+	rateLimitedCount int64
+	// rateLimitedSpanCount is the number of spans of such traces, it's updated atomically
+	rateLimitedSpanCount int64
+	// reportRateLimited makes the criteria checked for the traces exceeding the rate limit, so they are counted
+	reportRateLimited bool
+	// secondChanceOnRateLimit makes the evaluator return SecondChance for the matching traces exceeding the rate limit
+	secondChanceOnRateLimit bool
 
 	invertMatch bool
+	// drop makes the evaluator return Drop rather than Sampled for the matching traces
+	drop bool
+
+	// sizeBias makes the evaluator prefer smaller (when positive) or larger (when negative) traces
+	sizeBias float64
+	random   *rand.Rand
+	// probabilityKey (set for the probabilistic filter) is the span attribute holding the upstream sampling probability
+	probabilityKey string
 
 	logger *zap.Logger
 }
 
 var _ PolicyEvaluator = (*policyEvaluator)(nil)
+var _ RateLimitReporter = (*policyEvaluator)(nil)
 
-func createNumericAttributeFilter(cfg *config.NumericAttributeCfg) *numericAttributeFilter {
+func createNumericAttributeFilter(cfg *config.NumericAttributeCfg) (*numericAttributeFilter, error) {
 	if cfg == nil {
-		return nil
+		return nil, nil
 	}
 
-	return &numericAttributeFilter{
+	filter := &numericAttributeFilter{
 		key:      cfg.Key,
 		minValue: cfg.MinValue,
 		maxValue: cfg.MaxValue,
 	}
+
+	if cfg.MinDoubleValue != nil || cfg.MaxDoubleValue != nil {
+		if cfg.MinDoubleValue == nil || cfg.MaxDoubleValue == nil {
+			return nil, errors.New("both min and max double values must be provided for numeric attribute")
+		}
+		if math.IsNaN(*cfg.MinDoubleValue) || math.IsNaN(*cfg.MaxDoubleValue) {
+			return nil, errors.New("min and max double values of numeric attribute must be numbers")
+		}
+		filter.doubleBounds = true
+		filter.minDoubleValue = *cfg.MinDoubleValue
+		filter.maxDoubleValue = *cfg.MaxDoubleValue
+	}
+
+	return filter, nil
 }
 
-func createStringAttributeFilter(cfg *config.StringAttributeCfg) *stringAttributeFilter {
+func createStringAttributeFilter(cfg *config.StringAttributeCfg) (*stringAttributeFilter, error) {
 	if cfg == nil {
-		return nil
+		return nil, nil
 	}
 
 	valuesMap := make(map[string]struct{})
@@ -77,29 +210,394 @@ func createStringAttributeFilter(cfg *config.StringAttributeCfg) *stringAttribut
 		}
 	}
 
+	var patterns []*regexp.Regexp
+	for _, pattern := range cfg.ValuePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string attribute value pattern %q: %v", pattern, err)
+		}
+		patterns = append(patterns, re)
+	}
+
 	return &stringAttributeFilter{
-		key:    cfg.Key,
-		values: valuesMap,
+		key:         cfg.Key,
+		values:      valuesMap,
+		patterns:    patterns,
+		invertMatch: cfg.InvertMatch,
+	}, nil
+}
+
+func createBooleanAttributeFilter(cfg *config.BooleanAttributeCfg) (*booleanAttributeFilter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	if cfg.Key == "" {
+		return nil, errors.New("key must be provided for boolean attribute filter")
+	}
+
+	return &booleanAttributeFilter{
+		key:   cfg.Key,
+		value: cfg.Value,
+	}, nil
+}
+
+func createResourceAttributeFilter(cfg *config.ResourceAttributeCfg) (*stringAttributeFilter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	if cfg.Key == "" {
+		return nil, errors.New("key must be provided for resource attribute filter")
+	}
+
+	return createStringAttributeFilter(&config.StringAttributeCfg{
+		Key:    cfg.Key,
+		Values: cfg.Values,
+	})
+}
+
+func createCrossFieldMatchFilter(cfg *config.CrossFieldMatchCfg) (*crossFieldMatchFilter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	if cfg.ResourceKey == "" || cfg.SpanKey == "" {
+		return nil, errors.New("both resource and span keys must be provided for cross field match")
+	}
+
+	return &crossFieldMatchFilter{
+		resourceKey: cfg.ResourceKey,
+		spanKey:     cfg.SpanKey,
+	}, nil
+}
+
+func createNumericComparisonFilter(cfg *config.NumericComparisonCfg) (*numericComparisonFilter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	if cfg.FirstKey == "" || cfg.SecondKey == "" {
+		return nil, errors.New("both first and second keys must be provided for numeric comparison")
+	}
+
+	var operator comparisonOperator
+	switch cfg.Operator {
+	case "gt":
+		operator = greaterThan
+	case "lt":
+		operator = lessThan
+	case "eq":
+		operator = equalTo
+	default:
+		return nil, fmt.Errorf("unknown numeric comparison operator %q, must be one of: gt, lt, eq", cfg.Operator)
+	}
+
+	return &numericComparisonFilter{
+		firstKey:  cfg.FirstKey,
+		secondKey: cfg.SecondKey,
+		operator:  operator,
+	}, nil
+}
+
+func createDistinctAttributeValuesFilter(cfg *config.MinDistinctAttributeValuesCfg) (*distinctAttributeValuesFilter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	if cfg.Key == "" {
+		return nil, errors.New("key must be provided for min distinct attribute values")
+	}
+
+	if cfg.MinValues < 1 {
+		return nil, errors.New("min distinct attribute values must be a positive number")
+	}
+
+	return &distinctAttributeValuesFilter{
+		key:       cfg.Key,
+		minValues: cfg.MinValues,
+	}, nil
+}
+
+const (
+	subPoliciesOperatorAnd = "and"
+	subPoliciesOperatorOr  = "or"
+)
+
+func createSubPoliciesFilter(logger *zap.Logger, operator string, subPolicies []config.PolicyCfg) (*subPoliciesFilter, error) {
+	if len(subPolicies) == 0 {
+		return nil, nil
+	}
+
+	if operator == "" {
+		operator = subPoliciesOperatorAnd
+	}
+	if operator != subPoliciesOperatorAnd && operator != subPoliciesOperatorOr {
+		return nil, fmt.Errorf("unknown sub-policies operator: %s", operator)
+	}
+
+	evaluators := make([]*policyEvaluator, 0, len(subPolicies))
+	for i := range subPolicies {
+		evaluator, err := newPolicyEvaluator(logger, &subPolicies[i])
+		if err != nil {
+			return nil, err
+		}
+		evaluators = append(evaluators, evaluator)
+	}
+
+	return &subPoliciesFilter{
+		operator:   operator,
+		evaluators: evaluators,
+	}, nil
+}
+
+// subPolicyCfgs converts the sub-policies to the policy configs having only their criteria (and sub-policies)
+func subPolicyCfgs(subPolicies []config.SubPolicyCfg) []config.PolicyCfg {
+	cfgs := make([]config.PolicyCfg, len(subPolicies))
+	for i, subPolicy := range subPolicies {
+		cfgs[i] = criteriaPolicyCfg(subPolicy.PolicyCriteriaCfg)
+		cfgs[i].Operator = subPolicy.Operator
+		for _, nested := range subPolicy.SubPolicies {
+			cfgs[i].SubPolicies = append(cfgs[i].SubPolicies, config.SubPolicyCfg{PolicyCriteriaCfg: nested})
+		}
 	}
+	return cfgs
 }
 
-// NewProbabilisticFilter creates a policy evaluator intended for selecting samples probabilistically
-func NewProbabilisticFilter(logger *zap.Logger, maxSpanRate int64) (PolicyEvaluator, error) {
+// criteriaPolicyCfg returns the policy config having the given criteria, it must copy all of them
+func criteriaPolicyCfg(criteria config.PolicyCriteriaCfg) config.PolicyCfg {
+	return config.PolicyCfg{
+		Name:                          criteria.Name,
+		NumericAttributeCfg:           criteria.NumericAttributeCfg,
+		StringAttributeCfg:            criteria.StringAttributeCfg,
+		BooleanAttributeCfg:           criteria.BooleanAttributeCfg,
+		ResourceAttributeCfg:          criteria.ResourceAttributeCfg,
+		CrossFieldMatchCfg:            criteria.CrossFieldMatchCfg,
+		NumericComparisonCfg:          criteria.NumericComparisonCfg,
+		MinDistinctAttributeValuesCfg: criteria.MinDistinctAttributeValuesCfg,
+		ErrorCfg:                      criteria.ErrorCfg,
+		CIDRMatchCfg:                  criteria.CIDRMatchCfg,
+		StatusCodeCfg:                 criteria.StatusCodeCfg,
+		NewDeploymentCfg:              criteria.NewDeploymentCfg,
+		PropertiesCfg:                 criteria.PropertiesCfg,
+		InvertMatch:                   criteria.InvertMatch,
+	}
+}
+
+func createErrorFilter(cfg *config.ErrorCfg) *errorFilter {
+	if cfg == nil {
+		return nil
+	}
+
+	return &errorFilter{
+		childErrorOnly: cfg.ChildErrorOnly,
+	}
+}
+
+// parseSpanKinds returns the set of span kinds with given names, or nil when none are provided
+func parseSpanKinds(names []string) (map[pdata.SpanKind]struct{}, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	spanKinds := make(map[pdata.SpanKind]struct{}, len(names))
+	for _, name := range names {
+		switch name {
+		case "SERVER":
+			spanKinds[pdata.SpanKindSERVER] = struct{}{}
+		case "CLIENT":
+			spanKinds[pdata.SpanKindCLIENT] = struct{}{}
+		case "PRODUCER":
+			spanKinds[pdata.SpanKindPRODUCER] = struct{}{}
+		case "CONSUMER":
+			spanKinds[pdata.SpanKindCONSUMER] = struct{}{}
+		case "INTERNAL":
+			spanKinds[pdata.SpanKindINTERNAL] = struct{}{}
+		case "UNSPECIFIED":
+			spanKinds[pdata.SpanKindUNSPECIFIED] = struct{}{}
+		default:
+			return nil, fmt.Errorf("unknown span kind %q, must be one of: SERVER, CLIENT, PRODUCER, CONSUMER, INTERNAL, UNSPECIFIED", name)
+		}
+	}
+	return spanKinds, nil
+}
+
+func createStatusCodeFilter(cfg *config.StatusCodeCfg) (*statusCodeFilter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	if len(cfg.StatusCodes) == 0 {
+		return nil, errors.New("at least one status code must be provided for status code filter")
+	}
+
+	statusCodes := make(map[pdata.StatusCode]struct{}, len(cfg.StatusCodes))
+	for _, statusCode := range cfg.StatusCodes {
+		switch statusCode {
+		case "OK":
+			statusCodes[pdata.StatusCodeOk] = struct{}{}
+		case "ERROR":
+			statusCodes[pdata.StatusCodeError] = struct{}{}
+		case "UNSET":
+			statusCodes[pdata.StatusCodeUnset] = struct{}{}
+		default:
+			return nil, fmt.Errorf("unknown status code %q, must be one of: OK, ERROR, UNSET", statusCode)
+		}
+	}
+
+	return &statusCodeFilter{
+		statusCodes: statusCodes,
+	}, nil
+}
+
+const defaultMaxDeployments = 1000
+
+func createNewDeploymentFilter(cfg *config.NewDeploymentCfg) (*newDeploymentFilter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	if cfg.Key == "" {
+		return nil, errors.New("key must be provided for new deployment filter")
+	}
+
+	if cfg.MaxTraces < 0 || cfg.Duration < 0 {
+		return nil, errors.New("max traces and duration of new deployment filter must not be negative")
+	}
+
+	if cfg.MaxTraces == 0 && cfg.Duration == 0 {
+		return nil, errors.New("either max traces or duration must be provided for new deployment filter")
+	}
+
+	maxDeployments := cfg.MaxDeployments
+	if maxDeployments < 0 {
+		return nil, errors.New("max deployments of new deployment filter must not be negative")
+	}
+	if maxDeployments == 0 {
+		maxDeployments = defaultMaxDeployments
+	}
+
+	return &newDeploymentFilter{
+		key:            cfg.Key,
+		maxTraces:      cfg.MaxTraces,
+		duration:       cfg.Duration,
+		maxDeployments: maxDeployments,
+		now:            time.Now,
+		deployments:    make(map[string]*deploymentSighting),
+	}, nil
+}
+
+func createCIDRMatchFilter(cfg *config.CIDRMatchCfg) (*cidrMatchFilter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	if cfg.Key == "" {
+		return nil, errors.New("key must be provided for cidr match")
+	}
+
+	if len(cfg.CIDRs) == 0 {
+		return nil, errors.New("at least one CIDR must be provided for cidr match")
+	}
+
+	networks := make([]*net.IPNet, 0, len(cfg.CIDRs))
+	for _, cidr := range cfg.CIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q for cidr match: %v", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+
+	return &cidrMatchFilter{
+		key:      cfg.Key,
+		networks: networks,
+	}, nil
+}
+
+// NewProbabilisticFilter creates a policy evaluator intended for selecting samples probabilistically.
+// Non-zero sizeBias makes it prefer smaller (when positive) or larger (when negative) traces. The probability
+// the trace was already sampled with upstream is read from probabilityKey attribute, so it can be combined with
+// the rate of the filter.
+func NewProbabilisticFilter(logger *zap.Logger, maxSpanRate int64, sizeBias float64, probabilityKey string) (PolicyEvaluator, error) {
+	if math.IsNaN(sizeBias) || math.IsInf(sizeBias, 0) {
+		return nil, errors.New("probabilistic filtering size bias must be a finite number")
+	}
+
 	return &policyEvaluator{
 		logger:               logger,
 		currentSecond:        0,
 		spansInCurrentSecond: 0,
 		maxSpansPerSecond:    maxSpanRate,
+		sizeBias:             sizeBias,
+		random:               rand.New(rand.NewSource(time.Now().UnixNano())),
+		probabilityKey:       probabilityKey,
 	}, nil
 }
 
 // NewFilter creates a policy evaluator that samples all traces with the specified criteria
 func NewFilter(logger *zap.Logger, cfg *config.PolicyCfg) (PolicyEvaluator, error) {
-	numericAttrFilter := createNumericAttributeFilter(cfg.NumericAttributeCfg)
-	stringAttrFilter := createStringAttributeFilter(cfg.StringAttributeCfg)
+	pe, err := newPolicyEvaluator(logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return pe, nil
+}
+
+func newPolicyEvaluator(logger *zap.Logger, cfg *config.PolicyCfg) (*policyEvaluator, error) {
+	spanErrorFilter := createErrorFilter(cfg.ErrorCfg)
+
+	numericAttrFilter, err := createNumericAttributeFilter(cfg.NumericAttributeCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	stringAttrFilter, err := createStringAttributeFilter(cfg.StringAttributeCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	booleanAttrFilter, err := createBooleanAttributeFilter(cfg.BooleanAttributeCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceAttrFilter, err := createResourceAttributeFilter(cfg.ResourceAttributeCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	crossFieldFilter, err := createCrossFieldMatchFilter(cfg.CrossFieldMatchCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	numericCompFilter, err := createNumericComparisonFilter(cfg.NumericComparisonCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	distinctAttrsFilter, err := createDistinctAttributeValuesFilter(cfg.MinDistinctAttributeValuesCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cidrFilter, err := createCIDRMatchFilter(cfg.CIDRMatchCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	statusFilter, err := createStatusCodeFilter(cfg.StatusCodeCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	newDeploymentFilter, err := createNewDeploymentFilter(cfg.NewDeploymentCfg)
+	if err != nil {
+		return nil, err
+	}
 
 	var operationRe *regexp.Regexp
-	var err error
 
 	if cfg.PropertiesCfg.NamePattern != nil {
 		operationRe, err = regexp.Compile(*cfg.PropertiesCfg.NamePattern)
@@ -116,16 +614,68 @@ func NewFilter(logger *zap.Logger, cfg *config.PolicyCfg) (PolicyEvaluator, erro
 		return nil, errors.New("minimum number of spans must be a positive number")
 	}
 
+	if (cfg.PropertiesCfg.MinEstimatedSize != nil && *cfg.PropertiesCfg.MinEstimatedSize < 0) ||
+		(cfg.PropertiesCfg.MaxEstimatedSize != nil && *cfg.PropertiesCfg.MaxEstimatedSize < 0) {
+		return nil, errors.New("estimated trace size must be a non-negative number")
+	}
+
+	if cfg.PropertiesCfg.MinEstimatedSize != nil && cfg.PropertiesCfg.MaxEstimatedSize != nil &&
+		*cfg.PropertiesCfg.MinEstimatedSize > *cfg.PropertiesCfg.MaxEstimatedSize {
+		return nil, errors.New("minimum estimated trace size must not exceed the maximum one")
+	}
+
+	if cfg.PropertiesCfg.MinErrorSpanRatio != nil && !(*cfg.PropertiesCfg.MinErrorSpanRatio >= 0 && *cfg.PropertiesCfg.MinErrorSpanRatio <= 1) {
+		return nil, errors.New("minimum error span ratio must be within [0, 1]")
+	}
+
+	if cfg.PropertiesCfg.MinNumberOfErrors != nil && *cfg.PropertiesCfg.MinNumberOfErrors < 1 {
+		return nil, errors.New("minimum number of errors must be a positive number")
+	}
+
+	if cfg.PropertiesCfg.MinNetworkLatency != nil && *cfg.PropertiesCfg.MinNetworkLatency <= 0 {
+		return nil, errors.New("minimum network latency must be a positive duration")
+	}
+
+	spanKinds, err := parseSpanKinds(cfg.PropertiesCfg.SpanKinds)
+	if err != nil {
+		return nil, err
+	}
+
+	subPoliciesFilter, err := createSubPoliciesFilter(logger, cfg.Operator, subPolicyCfgs(cfg.SubPolicies))
+	if err != nil {
+		return nil, err
+	}
+
 	return &policyEvaluator{
 		stringAttr:           stringAttrFilter,
 		numericAttr:          numericAttrFilter,
+		resourceAttr:         resourceAttrFilter,
+		booleanAttr:          booleanAttrFilter,
+		crossFieldMatch:      crossFieldFilter,
+		numericComparison:    numericCompFilter,
+		distinctAttrs:        distinctAttrsFilter,
+		spanError:            spanErrorFilter,
+		cidrMatch:            cidrFilter,
+		statusCode:           statusFilter,
+		newDeployment:        newDeploymentFilter,
+		subPolicies:          subPoliciesFilter,
 		operationRe:          operationRe,
 		minDuration:          cfg.PropertiesCfg.MinDuration,
 		minNumberOfSpans:     cfg.PropertiesCfg.MinNumberOfSpans,
+		minEstimatedSize:     cfg.PropertiesCfg.MinEstimatedSize,
+		maxEstimatedSize:     cfg.PropertiesCfg.MaxEstimatedSize,
+		minErrorRatio:        cfg.PropertiesCfg.MinErrorSpanRatio,
+		minNumberOfErrors:    cfg.PropertiesCfg.MinNumberOfErrors,
+		remoteParent:         cfg.PropertiesCfg.RemoteParent,
+		spanKinds:            spanKinds,
+		minNetworkLatency:    cfg.PropertiesCfg.MinNetworkLatency,
 		logger:               logger,
 		currentSecond:        0,
 		spansInCurrentSecond: 0,
 		maxSpansPerSecond:    cfg.SpansPerSecond,
 		invertMatch:          cfg.InvertMatch,
+		drop:                 cfg.Drop,
+
+		secondChanceOnRateLimit: cfg.SecondChanceOnRateLimit,
 	}, nil
 }
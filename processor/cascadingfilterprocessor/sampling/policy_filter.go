@@ -15,6 +15,10 @@
 package sampling
 
 import (
+	"math"
+	"net"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/collector/consumer/pdata"
@@ -32,14 +36,27 @@ func tsToMicros(ts pdata.TimestampUnixNano) int64 {
 	return int64(ts / 1000)
 }
 
+// checkIfNumericAttrFound compares int attributes with the int bounds exactly, unless the double bounds are set.
+// Double attributes (or any numeric ones with the double bounds) are compared as floating point numbers
 func checkIfNumericAttrFound(attrs pdata.AttributeMap, filter *numericAttributeFilter) bool {
-	if v, ok := attrs.Get(filter.key); ok {
+	v, ok := attrs.Get(filter.key)
+	if !ok {
+		return false
+	}
+
+	if v.Type() == pdata.AttributeValueINT && !filter.doubleBounds {
 		value := v.IntVal()
-		if value >= filter.minValue && value <= filter.maxValue {
-			return true
-		}
+		return value >= filter.minValue && value <= filter.maxValue
 	}
-	return false
+
+	value, ok := numericValue(v)
+	if !ok {
+		return false
+	}
+	if filter.doubleBounds {
+		return value >= filter.minDoubleValue && value <= filter.maxDoubleValue
+	}
+	return value >= float64(filter.minValue) && value <= float64(filter.maxValue)
 }
 
 func checkIfStringAttrFound(attrs pdata.AttributeMap, filter *stringAttributeFilter) bool {
@@ -49,13 +66,318 @@ func checkIfStringAttrFound(attrs pdata.AttributeMap, filter *stringAttributeFil
 			if _, ok := filter.values[truncableStr]; ok {
 				return true
 			}
+			for _, re := range filter.patterns {
+				if re.MatchString(truncableStr) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func checkIfBooleanAttrFound(attrs pdata.AttributeMap, filter *booleanAttributeFilter) bool {
+	if v, ok := attrs.Get(filter.key); ok && v.Type() == pdata.AttributeValueBOOL {
+		return v.BoolVal() == filter.value
+	}
+	return false
+}
+
+func attributeValuesEqual(a pdata.AttributeValue, b pdata.AttributeValue) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a.Type() {
+	case pdata.AttributeValueSTRING:
+		return a.StringVal() == b.StringVal()
+	case pdata.AttributeValueINT:
+		return a.IntVal() == b.IntVal()
+	case pdata.AttributeValueDOUBLE:
+		return a.DoubleVal() == b.DoubleVal()
+	case pdata.AttributeValueBOOL:
+		return a.BoolVal() == b.BoolVal()
+	default:
+		return false
+	}
+}
+
+func checkIfCrossFieldMatches(resourceValue pdata.AttributeValue, spanAttrs pdata.AttributeMap, filter *crossFieldMatchFilter) bool {
+	if v, ok := spanAttrs.Get(filter.spanKey); ok {
+		return attributeValuesEqual(resourceValue, v)
+	}
+	return false
+}
+
+// numericValue returns the value of int or double attribute, other types are not considered numeric
+func numericValue(v pdata.AttributeValue) (float64, bool) {
+	switch v.Type() {
+	case pdata.AttributeValueINT:
+		return float64(v.IntVal()), true
+	case pdata.AttributeValueDOUBLE:
+		return v.DoubleVal(), true
+	default:
+		return 0, false
+	}
+}
+
+func checkIfNumericComparisonMatches(attrs pdata.AttributeMap, filter *numericComparisonFilter) bool {
+	first, ok := attrs.Get(filter.firstKey)
+	if !ok {
+		return false
+	}
+	second, ok := attrs.Get(filter.secondKey)
+	if !ok {
+		return false
+	}
+
+	firstValue, ok := numericValue(first)
+	if !ok {
+		return false
+	}
+	secondValue, ok := numericValue(second)
+	if !ok {
+		return false
+	}
+
+	switch filter.operator {
+	case greaterThan:
+		return firstValue > secondValue
+	case lessThan:
+		return firstValue < secondValue
+	case equalTo:
+		return firstValue == secondValue
+	default:
+		return false
+	}
+}
+
+// distinctValuesCounter counts distinct values of an attribute. It stops collecting them once the threshold
+// is reached, so the memory used for a single trace is bounded
+type distinctValuesCounter struct {
+	filter *distinctAttributeValuesFilter
+	values map[string]struct{}
+}
+
+func newDistinctValuesCounter(filter *distinctAttributeValuesFilter) *distinctValuesCounter {
+	return &distinctValuesCounter{
+		filter: filter,
+		values: make(map[string]struct{}, filter.minValues),
+	}
+}
+
+// distinctValueKey returns a representation of the value which differs for values of different types
+func distinctValueKey(v pdata.AttributeValue) (string, bool) {
+	switch v.Type() {
+	case pdata.AttributeValueSTRING:
+		return "s:" + v.StringVal(), true
+	case pdata.AttributeValueINT:
+		return "i:" + strconv.FormatInt(v.IntVal(), 10), true
+	case pdata.AttributeValueDOUBLE:
+		return "d:" + strconv.FormatFloat(v.DoubleVal(), 'g', -1, 64), true
+	case pdata.AttributeValueBOOL:
+		return "b:" + strconv.FormatBool(v.BoolVal()), true
+	default:
+		return "", false
+	}
+}
+
+func (dvc *distinctValuesCounter) add(attrs pdata.AttributeMap) {
+	if dvc.thresholdReached() {
+		return
+	}
+	if v, ok := attrs.Get(dvc.filter.key); ok {
+		if key, ok := distinctValueKey(v); ok {
+			dvc.values[key] = struct{}{}
+		}
+	}
+}
+
+func (dvc *distinctValuesCounter) thresholdReached() bool {
+	return len(dvc.values) >= dvc.filter.minValues
+}
+
+// checkIfCIDRMatches returns true if the attribute holds an IP address within any of the filter networks.
+// Values which are not strings or cannot be parsed as an IP address never match
+func checkIfCIDRMatches(attrs pdata.AttributeMap, filter *cidrMatchFilter) bool {
+	v, ok := attrs.Get(filter.key)
+	if !ok || v.Type() != pdata.AttributeValueSTRING {
+		return false
+	}
+
+	ip := net.ParseIP(v.StringVal())
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range filter.networks {
+		if network.Contains(ip) {
+			return true
 		}
 	}
 	return false
 }
 
+func checkIfStatusCodeFound(span pdata.Span, filter *statusCodeFilter) bool {
+	_, found := filter.statusCodes[span.Status().Code()]
+	return found
+}
+
+func checkIfErrorFound(span pdata.Span, filter *errorFilter) bool {
+	if span.Status().Code() != pdata.StatusCodeError {
+		return false
+	}
+	return !filter.childErrorOnly || !span.ParentSpanID().IsEmpty()
+}
+
+// traceOrigin describes whether the trace was initiated within the traced system or externally
+type traceOrigin int
+
+const (
+	// originUnknown is used when the root span is not present (perhaps not arrived yet) and no span seems
+	// to be an entry point of a remote call
+	originUnknown traceOrigin = iota
+	originInternal
+	originExternal
+)
+
+// originTracker collects span relations needed to determine the trace origin
+type originTracker struct {
+	rootFound bool
+	spanIDs   map[[8]byte]struct{}
+	// parents of server and consumer spans, which are potential entry points of remote calls
+	entrySpanParents [][8]byte
+}
+
+func newOriginTracker() *originTracker {
+	return &originTracker{spanIDs: make(map[[8]byte]struct{})}
+}
+
+func (ot *originTracker) add(span pdata.Span) {
+	ot.spanIDs[span.SpanID().Bytes()] = struct{}{}
+	if span.ParentSpanID().IsEmpty() {
+		ot.rootFound = true
+	} else if span.Kind() == pdata.SpanKindSERVER || span.Kind() == pdata.SpanKindCONSUMER {
+		ot.entrySpanParents = append(ot.entrySpanParents, span.ParentSpanID().Bytes())
+	}
+}
+
+func (ot *originTracker) origin() traceOrigin {
+	if ot.rootFound {
+		return originInternal
+	}
+	for _, parentID := range ot.entrySpanParents {
+		if _, found := ot.spanIDs[parentID]; !found {
+			return originExternal
+		}
+	}
+	return originUnknown
+}
+
+// nonStringValueSize is the estimated size of attribute values which are not strings
+const nonStringValueSize = 8
+
+// estimatedAttributesSize approximates the serialized size of the attributes as the total length of their keys
+// and values
+func estimatedAttributesSize(attrs pdata.AttributeMap) int64 {
+	size := int64(0)
+	attrs.ForEach(func(k string, v pdata.AttributeValue) {
+		size += int64(len(k))
+		if v.Type() == pdata.AttributeValueSTRING {
+			size += int64(len(v.StringVal()))
+		} else {
+			size += nonStringValueSize
+		}
+	})
+	return size
+}
+
+// networkLatencyTracker collects the durations of client spans and of the server spans they called,
+// which are correlated by the span and parent span IDs
+type networkLatencyTracker struct {
+	clientDurations map[[8]byte]int64
+	serverDurations map[[8]byte]int64
+}
+
+func newNetworkLatencyTracker() *networkLatencyTracker {
+	return &networkLatencyTracker{
+		clientDurations: make(map[[8]byte]int64),
+		serverDurations: make(map[[8]byte]int64),
+	}
+}
+
+func spanDuration(span pdata.Span) int64 {
+	return int64(span.EndTime()) - int64(span.StartTime())
+}
+
+func (nt *networkLatencyTracker) add(span pdata.Span) {
+	switch span.Kind() {
+	case pdata.SpanKindCLIENT:
+		nt.clientDurations[span.SpanID().Bytes()] = spanDuration(span)
+	case pdata.SpanKindSERVER:
+		if !span.ParentSpanID().IsEmpty() {
+			// When there are several server spans for the same client span (e.g. retries), the longest one is used
+			parentID := span.ParentSpanID().Bytes()
+			if duration, ok := nt.serverDurations[parentID]; !ok || spanDuration(span) > duration {
+				nt.serverDurations[parentID] = spanDuration(span)
+			}
+		}
+	}
+}
+
+// maxGap returns the largest difference between the duration of a client span and the duration of its server span.
+// It returns false when no client/server span pair was found
+func (nt *networkLatencyTracker) maxGap() (time.Duration, bool) {
+	found := false
+	maxGap := int64(0)
+	for parentID, serverDuration := range nt.serverDurations {
+		clientDuration, ok := nt.clientDurations[parentID]
+		if !ok {
+			continue
+		}
+		if gap := clientDuration - serverDuration; !found || gap > maxGap {
+			maxGap = gap
+			found = true
+		}
+	}
+	return time.Duration(maxGap), found
+}
+
+// isNew accounts the trace for each of the deployments (unless it was already) and returns true if any of them is
+// still within the period following its first sighting
+func (ndf *newDeploymentFilter) isNew(traceID pdata.TraceID, deployments map[string]struct{}) bool {
+	ndf.lock.Lock()
+	defer ndf.lock.Unlock()
+
+	now := ndf.now()
+	found := false
+	for deployment := range deployments {
+		sighting, ok := ndf.deployments[deployment]
+		if !ok {
+			if len(ndf.order) >= ndf.maxDeployments {
+				delete(ndf.deployments, ndf.order[0])
+				ndf.order = ndf.order[1:]
+			}
+			sighting = &deploymentSighting{firstSeen: now, traceIDs: make(map[[16]byte]struct{})}
+			ndf.deployments[deployment] = sighting
+			ndf.order = append(ndf.order, deployment)
+		}
+		_, counted := sighting.traceIDs[traceID.Bytes()]
+		if !counted && int64(len(sighting.traceIDs)) < ndf.maxTraces {
+			sighting.traceIDs[traceID.Bytes()] = struct{}{}
+			counted = true
+		}
+
+		if (ndf.maxTraces == 0 || counted) &&
+			(ndf.duration == 0 || now.Sub(sighting.firstSeen) < ndf.duration) {
+			found = true
+		}
+	}
+	return found
+}
+
 // evaluateRules goes through the defined properties and checks if they are matched
-func (pe *policyEvaluator) evaluateRules(_ pdata.TraceID, trace *TraceData) Decision {
+func (pe *policyEvaluator) evaluateRules(traceID pdata.TraceID, trace *TraceData) Decision {
 	trace.Lock()
 	batches := trace.ReceivedBatches
 	trace.Unlock()
@@ -63,14 +385,51 @@ func (pe *policyEvaluator) evaluateRules(_ pdata.TraceID, trace *TraceData) Deci
 	matchingOperationFound := false
 	matchingStringAttrFound := false
 	matchingNumericAttrFound := false
+	matchingResourceAttrFound := false
+	matchingBooleanAttrFound := false
+	matchingCrossFieldFound := false
+	matchingNumericComparisonFound := false
+	matchingErrorFound := false
+	matchingCIDRFound := false
+	matchingStatusCodeFound := false
+	matchingSpanKindFound := false
 	spanCount := 0
+	errorSpanCount := 0
 	minStartTime := int64(0)
 	maxEndTime := int64(0)
+	estimatedSize := int64(0)
+	estimateSize := pe.minEstimatedSize != nil || pe.maxEstimatedSize != nil
+
+	var origin *originTracker
+	if pe.remoteParent != nil {
+		origin = newOriginTracker()
+	}
+
+	var networkLatency *networkLatencyTracker
+	if pe.minNetworkLatency != nil {
+		networkLatency = newNetworkLatencyTracker()
+	}
+
+	var deployments map[string]struct{}
+	if pe.newDeployment != nil {
+		deployments = make(map[string]struct{})
+	}
+
+	var distinctValues *distinctValuesCounter
+	if pe.distinctAttrs != nil {
+		distinctValues = newDistinctValuesCounter(pe.distinctAttrs)
+	}
 
 	for _, batch := range batches {
 		rs := batch.ResourceSpans()
 
 		for i := 0; i < rs.Len(); i++ {
+			var crossFieldResourceValue pdata.AttributeValue
+			crossFieldResourceValueFound := false
+			if pe.crossFieldMatch != nil && !matchingCrossFieldFound {
+				crossFieldResourceValue, crossFieldResourceValueFound = rs.At(i).Resource().Attributes().Get(pe.crossFieldMatch.resourceKey)
+			}
+
 			if pe.stringAttr != nil || pe.numericAttr != nil {
 				res := rs.At(i).Resource()
 				if !matchingStringAttrFound && pe.stringAttr != nil {
@@ -81,6 +440,34 @@ func (pe *policyEvaluator) evaluateRules(_ pdata.TraceID, trace *TraceData) Deci
 				}
 			}
 
+			if pe.booleanAttr != nil && !matchingBooleanAttrFound {
+				matchingBooleanAttrFound = checkIfBooleanAttrFound(rs.At(i).Resource().Attributes(), pe.booleanAttr)
+			}
+
+			if pe.resourceAttr != nil && !matchingResourceAttrFound {
+				matchingResourceAttrFound = checkIfStringAttrFound(rs.At(i).Resource().Attributes(), pe.resourceAttr)
+			}
+
+			if pe.cidrMatch != nil && !matchingCIDRFound {
+				matchingCIDRFound = checkIfCIDRMatches(rs.At(i).Resource().Attributes(), pe.cidrMatch)
+			}
+
+			if distinctValues != nil {
+				distinctValues.add(rs.At(i).Resource().Attributes())
+			}
+
+			if estimateSize {
+				estimatedSize += estimatedAttributesSize(rs.At(i).Resource().Attributes())
+			}
+
+			if deployments != nil {
+				if v, ok := rs.At(i).Resource().Attributes().Get(pe.newDeployment.key); ok {
+					if key, ok := distinctValueKey(v); ok {
+						deployments[key] = struct{}{}
+					}
+				}
+			}
+
 			ils := rs.At(i).InstrumentationLibrarySpans()
 			for j := 0; j < ils.Len(); j++ {
 				spans := ils.At(j).Spans()
@@ -97,6 +484,54 @@ func (pe *policyEvaluator) evaluateRules(_ pdata.TraceID, trace *TraceData) Deci
 						}
 					}
 
+					if pe.booleanAttr != nil && !matchingBooleanAttrFound {
+						matchingBooleanAttrFound = checkIfBooleanAttrFound(span.Attributes(), pe.booleanAttr)
+					}
+
+					if crossFieldResourceValueFound && !matchingCrossFieldFound {
+						matchingCrossFieldFound = checkIfCrossFieldMatches(crossFieldResourceValue, span.Attributes(), pe.crossFieldMatch)
+					}
+
+					if pe.numericComparison != nil && !matchingNumericComparisonFound {
+						matchingNumericComparisonFound = checkIfNumericComparisonMatches(span.Attributes(), pe.numericComparison)
+					}
+
+					if distinctValues != nil {
+						distinctValues.add(span.Attributes())
+					}
+
+					if (pe.minErrorRatio != nil || pe.minNumberOfErrors != nil) && span.Status().Code() == pdata.StatusCodeError {
+						errorSpanCount++
+					}
+
+					if pe.spanError != nil && !matchingErrorFound {
+						matchingErrorFound = checkIfErrorFound(span, pe.spanError)
+					}
+
+					if pe.cidrMatch != nil && !matchingCIDRFound {
+						matchingCIDRFound = checkIfCIDRMatches(span.Attributes(), pe.cidrMatch)
+					}
+
+					if pe.statusCode != nil && !matchingStatusCodeFound {
+						matchingStatusCodeFound = checkIfStatusCodeFound(span, pe.statusCode)
+					}
+
+					if pe.spanKinds != nil && !matchingSpanKindFound {
+						_, matchingSpanKindFound = pe.spanKinds[span.Kind()]
+					}
+
+					if origin != nil {
+						origin.add(span)
+					}
+
+					if networkLatency != nil {
+						networkLatency.add(span)
+					}
+
+					if estimateSize {
+						estimatedSize += int64(len(span.Name())) + estimatedAttributesSize(span.Attributes())
+					}
+
 					if pe.operationRe != nil && !matchingOperationFound {
 						if pe.operationRe.MatchString(span.Name()) {
 							matchingOperationFound = true
@@ -126,21 +561,46 @@ func (pe *policyEvaluator) evaluateRules(_ pdata.TraceID, trace *TraceData) Deci
 	}
 
 	conditionMet := struct {
-		operationName, minDuration, minSpanCount, stringAttr, numericAttr bool
+		operationName, minDuration, minSpanCount, minErrorRatio, minErrorCount, stringAttr, numericAttr, booleanAttr, resourceAttr, crossField, numericComparison, distinctAttrs, spanError, cidrMatch, statusCode, spanKind, remoteParent, networkLatency, newDeployment, estimatedSize bool
 	}{
-		operationName: true,
-		minDuration:   true,
-		minSpanCount:  true,
-		stringAttr:    true,
-		numericAttr:   true,
+		operationName:     true,
+		minDuration:       true,
+		minSpanCount:      true,
+		minErrorRatio:     true,
+		minErrorCount:     true,
+		stringAttr:        true,
+		numericAttr:       true,
+		booleanAttr:       true,
+		resourceAttr:      true,
+		crossField:        true,
+		numericComparison: true,
+		distinctAttrs:     true,
+		spanError:         true,
+		cidrMatch:         true,
+		statusCode:        true,
+		spanKind:          true,
+		remoteParent:      true,
+		networkLatency:    true,
+		newDeployment:     true,
+		estimatedSize:     true,
 	}
 
 	if pe.operationRe != nil {
 		conditionMet.operationName = matchingOperationFound
 	}
+	if estimateSize {
+		conditionMet.estimatedSize = (pe.minEstimatedSize == nil || estimatedSize >= *pe.minEstimatedSize) &&
+			(pe.maxEstimatedSize == nil || estimatedSize <= *pe.maxEstimatedSize)
+	}
 	if pe.minNumberOfSpans != nil {
 		conditionMet.minSpanCount = spanCount >= *pe.minNumberOfSpans
 	}
+	if pe.minErrorRatio != nil {
+		conditionMet.minErrorRatio = spanCount > 0 && float64(errorSpanCount)/float64(spanCount) >= *pe.minErrorRatio
+	}
+	if pe.minNumberOfErrors != nil {
+		conditionMet.minErrorCount = errorSpanCount >= *pe.minNumberOfErrors
+	}
 	if pe.minDuration != nil {
 		conditionMet.minDuration = maxEndTime > minStartTime && maxEndTime-minStartTime >= pe.minDuration.Microseconds()
 	}
@@ -148,14 +608,76 @@ func (pe *policyEvaluator) evaluateRules(_ pdata.TraceID, trace *TraceData) Deci
 		conditionMet.numericAttr = matchingNumericAttrFound
 	}
 	if pe.stringAttr != nil {
-		conditionMet.stringAttr = matchingStringAttrFound
+		conditionMet.stringAttr = matchingStringAttrFound != pe.stringAttr.invertMatch
+	}
+	if pe.booleanAttr != nil {
+		conditionMet.booleanAttr = matchingBooleanAttrFound
+	}
+	if pe.resourceAttr != nil {
+		conditionMet.resourceAttr = matchingResourceAttrFound
+	}
+	if pe.crossFieldMatch != nil {
+		conditionMet.crossField = matchingCrossFieldFound
+	}
+	if pe.numericComparison != nil {
+		conditionMet.numericComparison = matchingNumericComparisonFound
+	}
+	if distinctValues != nil {
+		conditionMet.distinctAttrs = distinctValues.thresholdReached()
+	}
+	if pe.spanError != nil {
+		conditionMet.spanError = matchingErrorFound
+	}
+	if pe.cidrMatch != nil {
+		conditionMet.cidrMatch = matchingCIDRFound
+	}
+	if pe.statusCode != nil {
+		conditionMet.statusCode = matchingStatusCodeFound
+	}
+	if pe.spanKinds != nil {
+		conditionMet.spanKind = matchingSpanKindFound
+	}
+	if origin != nil {
+		switch origin.origin() {
+		case originExternal:
+			conditionMet.remoteParent = *pe.remoteParent
+		case originInternal:
+			conditionMet.remoteParent = !*pe.remoteParent
+		default:
+			conditionMet.remoteParent = false
+		}
+	}
+	if networkLatency != nil {
+		gap, found := networkLatency.maxGap()
+		conditionMet.networkLatency = found && gap >= *pe.minNetworkLatency
+	}
+	if deployments != nil {
+		// The traces are accounted for the deployments regardless of the other criteria
+		conditionMet.newDeployment = pe.newDeployment.isNew(traceID, deployments)
 	}
 
+	// The sub-policies are checked last and only if needed, as each of them goes through the spans again
 	if conditionMet.minSpanCount &&
+		conditionMet.minErrorRatio &&
+		conditionMet.minErrorCount &&
 		conditionMet.minDuration &&
 		conditionMet.operationName &&
 		conditionMet.numericAttr &&
-		conditionMet.stringAttr {
+		conditionMet.stringAttr &&
+		conditionMet.booleanAttr &&
+		conditionMet.resourceAttr &&
+		conditionMet.crossField &&
+		conditionMet.numericComparison &&
+		conditionMet.distinctAttrs &&
+		conditionMet.spanError &&
+		conditionMet.cidrMatch &&
+		conditionMet.statusCode &&
+		conditionMet.spanKind &&
+		conditionMet.remoteParent &&
+		conditionMet.networkLatency &&
+		conditionMet.newDeployment &&
+		conditionMet.estimatedSize &&
+		pe.subPoliciesMatch(traceID, trace) {
 		if pe.invertMatch {
 			return NotSampled
 		}
@@ -168,6 +690,24 @@ func (pe *policyEvaluator) evaluateRules(_ pdata.TraceID, trace *TraceData) Deci
 	return NotSampled
 }
 
+// subPoliciesMatch checks if the criteria of the sub-policies combined with the operator are met (or there are none)
+func (pe *policyEvaluator) subPoliciesMatch(traceID pdata.TraceID, trace *TraceData) bool {
+	if pe.subPolicies == nil {
+		return true
+	}
+
+	for _, evaluator := range pe.subPolicies.evaluators {
+		matched := evaluator.evaluateRules(traceID, trace) == Sampled
+		if pe.subPolicies.operator == subPoliciesOperatorOr && matched {
+			return true
+		}
+		if pe.subPolicies.operator == subPoliciesOperatorAnd && !matched {
+			return false
+		}
+	}
+	return pe.subPolicies.operator == subPoliciesOperatorAnd
+}
+
 func (pe *policyEvaluator) shouldConsider(currSecond int64, trace *TraceData) bool {
 	if pe.maxSpansPerSecond < 0 {
 		// This emits "second chance" traces
@@ -184,6 +724,25 @@ func (pe *policyEvaluator) shouldConsider(currSecond int64, trace *TraceData) bo
 	}
 }
 
+// sizeBiasKeepProbability returns the probability of keeping a trace with given number of spans. The size is taken
+// relative to the rate limit, so with positive bias a trace taking the whole budget is never kept, while with
+// negative bias it is always kept
+func (pe *policyEvaluator) sizeBiasKeepProbability(spanCount int64) float64 {
+	if pe.sizeBias == 0 || pe.maxSpansPerSecond <= 0 {
+		return 1.0
+	}
+
+	relativeSize := float64(spanCount) / float64(pe.maxSpansPerSecond)
+	if relativeSize > 1.0 {
+		relativeSize = 1.0
+	}
+
+	if pe.sizeBias > 0 {
+		return math.Pow(1.0-relativeSize, pe.sizeBias)
+	}
+	return math.Pow(relativeSize, -pe.sizeBias)
+}
+
 func (pe *policyEvaluator) emitsSecondChance() bool {
 	return pe.maxSpansPerSecond < 0
 }
@@ -206,20 +765,105 @@ func (pe *policyEvaluator) updateRate(currSecond int64, numSpans int64) Decision
 // Evaluate looks at the trace data and returns a corresponding SamplingDecision. Also takes into account
 // the usage of sampling rate budget
 func (pe *policyEvaluator) Evaluate(traceID pdata.TraceID, trace *TraceData) Decision {
+	if pe.drop {
+		// The matching traces are rejected, so there is no budget to account
+		if pe.evaluateRules(traceID, trace) == Sampled {
+			return Drop
+		}
+		return NotSampled
+	}
+
+	if pe.probabilityKey != "" {
+		setUpstreamSamplingProbability(trace, pe.probabilityKey)
+	}
+
 	currSecond := time.Now().Unix()
 
-	if !pe.shouldConsider(currSecond, trace) {
+	pe.rateLock.Lock()
+	consider := pe.shouldConsider(currSecond, trace)
+	pe.rateLock.Unlock()
+	if !consider && !pe.reportRateLimited && !pe.secondChanceOnRateLimit {
 		return NotSampled
 	}
 
+	// The rules are evaluated for the traces which do not fit the rate limit only if it makes a difference
 	decision := pe.evaluateRules(traceID, trace)
 	if decision != Sampled {
 		return decision
 	}
+	if !consider {
+		return pe.rateLimitedDecision(trace.SpanCount)
+	}
+
+	pe.rateLock.Lock()
+	defer pe.rateLock.Unlock()
+
+	if pe.sizeBias != 0 && pe.random.Float64() >= pe.sizeBiasKeepProbability(trace.SpanCount) {
+		return NotSampled
+	}
 
 	if pe.emitsSecondChance() {
 		return SecondChance
 	}
 
-	return pe.updateRate(currSecond, trace.SpanCount)
+	decision = pe.updateRate(currSecond, trace.SpanCount)
+	if decision == NotSampled {
+		return pe.rateLimitedDecision(trace.SpanCount)
+	}
+	return decision
+}
+
+// rateLimitedDecision returns the decision for a matching trace which exceeds the rate limit
+func (pe *policyEvaluator) rateLimitedDecision(spanCount int64) Decision {
+	if pe.secondChanceOnRateLimit {
+		return SecondChance
+	}
+	if pe.reportRateLimited {
+		atomic.AddInt64(&pe.rateLimitedCount, 1)
+		atomic.AddInt64(&pe.rateLimitedSpanCount, spanCount)
+	}
+	return NotSampled
+}
+
+// setUpstreamSamplingProbability records the lowest valid probability found in the spans of the trace
+func setUpstreamSamplingProbability(trace *TraceData, probabilityKey string) {
+	trace.Lock()
+	defer trace.Unlock()
+
+	probability := 0.0
+	for _, batch := range trace.ReceivedBatches {
+		rs := batch.ResourceSpans()
+		for i := 0; i < rs.Len(); i++ {
+			ils := rs.At(i).InstrumentationLibrarySpans()
+			for j := 0; j < ils.Len(); j++ {
+				spans := ils.At(j).Spans()
+				for k := 0; k < spans.Len(); k++ {
+					av, found := spans.At(k).Attributes().Get(probabilityKey)
+					if !found || av.Type() != pdata.AttributeValueDOUBLE {
+						continue
+					}
+					if p := av.DoubleVal(); p > 0 && p <= 1 && (probability == 0 || p < probability) {
+						probability = p
+					}
+				}
+			}
+		}
+	}
+	trace.UpstreamSamplingProbability = probability
+}
+
+// EnableRateLimitReporting makes the traces exceeding the rate limit checked against the criteria, so the matching
+// ones are counted. It must be called before the evaluator is used
+func (pe *policyEvaluator) EnableRateLimitReporting() {
+	pe.reportRateLimited = true
+}
+
+// RateLimitedCount returns the number of traces which matched the policy, but exceeded its rate limit
+func (pe *policyEvaluator) RateLimitedCount() int64 {
+	return atomic.LoadInt64(&pe.rateLimitedCount)
+}
+
+// RateLimitedSpanCount returns the number of spans of the traces which matched the policy, but exceeded its rate limit
+func (pe *policyEvaluator) RateLimitedSpanCount() int64 {
+	return atomic.LoadInt64(&pe.rateLimitedSpanCount)
 }
@@ -0,0 +1,120 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/translator/conventions"
+	"go.uber.org/zap"
+)
+
+func newSizeBiasedProbabilisticFilter(t *testing.T, maxSpanRate int64, sizeBias float64) *policyEvaluator {
+	filter, err := NewProbabilisticFilter(zap.NewNop(), maxSpanRate, sizeBias, conventions.AttributeSamplingProbability)
+	require.NoError(t, err)
+	pe := filter.(*policyEvaluator)
+	pe.random = rand.New(rand.NewSource(1))
+	return pe
+}
+
+func TestProbabilisticFilterSizeBias(t *testing.T) {
+	const evaluations = 10000
+	var empty = map[string]pdata.AttributeValue{}
+	traceID := pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+
+	cases := []struct {
+		Desc                string
+		SizeBias            float64
+		SpanCount           int64
+		ExpectedProbability float64
+	}{
+		{Desc: "no bias", SizeBias: 0, SpanCount: 50, ExpectedProbability: 1.0},
+		{Desc: "prefer small, small trace", SizeBias: 1, SpanCount: 10, ExpectedProbability: 0.9},
+		{Desc: "prefer small, large trace", SizeBias: 1, SpanCount: 50, ExpectedProbability: 0.5},
+		{Desc: "prefer small, strong bias", SizeBias: 2, SpanCount: 50, ExpectedProbability: 0.25},
+		{Desc: "prefer large, small trace", SizeBias: -1, SpanCount: 10, ExpectedProbability: 0.1},
+		{Desc: "prefer large, large trace", SizeBias: -1, SpanCount: 50, ExpectedProbability: 0.5},
+		{Desc: "prefer large, whole budget", SizeBias: -1, SpanCount: 100, ExpectedProbability: 1.0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			filter := newSizeBiasedProbabilisticFilter(t, 100, c.SizeBias)
+			assert.InDelta(t, c.ExpectedProbability, filter.sizeBiasKeepProbability(c.SpanCount), 1e-9)
+
+			trace := newTraceStringAttrs(empty, "example", "value")
+			trace.SpanCount = c.SpanCount
+
+			kept := 0
+			for i := 0; i < evaluations; i++ {
+				// Reset the rate limit, so only the bias determines the decision
+				filter.spansInCurrentSecond = 0
+				if filter.Evaluate(traceID, trace) == Sampled {
+					kept++
+				}
+			}
+			assert.InDelta(t, c.ExpectedProbability, float64(kept)/evaluations, 0.03)
+		})
+	}
+}
+
+func TestProbabilisticFilterInvalidSizeBias(t *testing.T) {
+	_, err := NewProbabilisticFilter(zap.NewNop(), 100, math.NaN(), conventions.AttributeSamplingProbability)
+	assert.Error(t, err)
+}
+
+func TestProbabilisticFilterUpstreamSamplingProbability(t *testing.T) {
+	traceID := pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	empty := map[string]pdata.AttributeValue{}
+
+	cases := []struct {
+		Desc                string
+		SpanProbabilities   []interface{}
+		ExpectedProbability float64
+	}{
+		{Desc: "without attribute", SpanProbabilities: []interface{}{nil, nil}, ExpectedProbability: 0},
+		{Desc: "with attribute", SpanProbabilities: []interface{}{0.25, 0.25}, ExpectedProbability: 0.25},
+		{Desc: "with attribute on some spans", SpanProbabilities: []interface{}{nil, 0.5}, ExpectedProbability: 0.5},
+		{Desc: "lowest probability", SpanProbabilities: []interface{}{0.5, 0.1}, ExpectedProbability: 0.1},
+		{Desc: "invalid values", SpanProbabilities: []interface{}{0.0, 1.5, "0.5"}, ExpectedProbability: 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			filter := newSizeBiasedProbabilisticFilter(t, 100, 0)
+
+			trace := newTraceStringAttrs(empty, "example", "value")
+			spans := trace.ReceivedBatches[0].ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans()
+			spans.Resize(len(c.SpanProbabilities))
+			for i, probability := range c.SpanProbabilities {
+				switch p := probability.(type) {
+				case float64:
+					spans.At(i).Attributes().UpsertDouble(conventions.AttributeSamplingProbability, p)
+				case string:
+					spans.At(i).Attributes().UpsertString(conventions.AttributeSamplingProbability, p)
+				}
+			}
+			trace.SpanCount = int64(spans.Len())
+
+			assert.Equal(t, Sampled, filter.Evaluate(traceID, trace))
+			assert.Equal(t, c.ExpectedProbability, trace.UpstreamSamplingProbability)
+		})
+	}
+}
@@ -16,16 +16,21 @@ package sampling
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/consumer/pdata"
 	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
 )
 
 func newRateLimiterFilter(maxRate int64) *policyEvaluator {
 	return &policyEvaluator{
 		logger:            zap.NewNop(),
 		maxSpansPerSecond: maxRate,
+		reportRateLimited: true,
 	}
 }
 
@@ -55,6 +60,99 @@ func TestRateLimiter(t *testing.T) {
 	trace.SpanCount = 0
 	decision = rateLimiter.Evaluate(traceID, trace)
 	assert.Equal(t, decision, Sampled)
+
+	// The traces which did not fit are reported as rate limited
+	assert.EqualValues(t, 2, rateLimiter.RateLimitedCount())
+	assert.EqualValues(t, 14, rateLimiter.RateLimitedSpanCount())
+}
+
+func TestRateLimitedTracesAreNotCheckedByDefault(t *testing.T) {
+	traceID := pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:               "limited",
+		SpansPerSecond:     3,
+		StringAttributeCfg: &config.StringAttributeCfg{Key: "example", Values: []string{"value"}},
+	})
+	require.NoError(t, err)
+
+	// The trace never fits the limit, so it's rejected without checking the criteria
+	trace := newTraceStringAttrs(map[string]pdata.AttributeValue{}, "example", "value")
+	trace.SpanCount = 10
+	assert.Equal(t, NotSampled, filter.Evaluate(traceID, trace))
+	assert.EqualValues(t, 0, filter.(RateLimitReporter).RateLimitedCount())
+
+	filter.(RateLimitReporter).EnableRateLimitReporting()
+	assert.Equal(t, NotSampled, filter.Evaluate(traceID, trace))
+	assert.EqualValues(t, 1, filter.(RateLimitReporter).RateLimitedCount())
+}
+
+func TestPolicySpansPerSecondIsExhausted(t *testing.T) {
+	var empty = map[string]pdata.AttributeValue{}
+	traceID := pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+
+	// The budget is renewed each second, so the evaluations are repeated if they happen to span two seconds
+	for attempt := 0; attempt < 3; attempt++ {
+		filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{Name: "limited", SpansPerSecond: 35})
+		require.NoError(t, err)
+
+		trace := newTraceStringAttrs(empty, "example", "value")
+		trace.SpanCount = 20
+
+		second := time.Now().Unix()
+		first := filter.Evaluate(traceID, trace)
+		secondTrace := filter.Evaluate(traceID, trace)
+		if time.Now().Unix() != second {
+			continue
+		}
+
+		assert.Equal(t, Sampled, first)
+		// The policy budget is exhausted, so the trace is left for the subsequent policies
+		assert.Equal(t, NotSampled, secondTrace)
+		return
+	}
+	t.Fatal("could not evaluate the traces within a single second")
+}
+
+func TestSecondChanceOnRateLimit(t *testing.T) {
+	var empty = map[string]pdata.AttributeValue{}
+	traceID := pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+
+	filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:                    "limited",
+		SpansPerSecond:          35,
+		SecondChanceOnRateLimit: true,
+		StringAttributeCfg:      &config.StringAttributeCfg{Key: "example", Values: []string{"value"}},
+	})
+	require.NoError(t, err)
+
+	// The trace never fits the policy limit, so it gets a second chance, unless it does not match
+	trace := newTraceStringAttrs(empty, "example", "value")
+	trace.SpanCount = 50
+	assert.Equal(t, SecondChance, filter.Evaluate(traceID, trace))
+
+	nonMatchingTrace := newTraceStringAttrs(empty, "example", "other")
+	nonMatchingTrace.SpanCount = 50
+	assert.Equal(t, NotSampled, filter.Evaluate(traceID, nonMatchingTrace))
+
+	// The budget is renewed each second, so the evaluations are repeated if they happen to span two seconds
+	for attempt := 0; attempt < 3; attempt++ {
+		filter, err = NewFilter(zap.NewNop(), &config.PolicyCfg{Name: "limited", SpansPerSecond: 35, SecondChanceOnRateLimit: true})
+		require.NoError(t, err)
+
+		trace.SpanCount = 20
+		second := time.Now().Unix()
+		first := filter.Evaluate(traceID, trace)
+		secondTrace := filter.Evaluate(traceID, trace)
+		if time.Now().Unix() != second {
+			continue
+		}
+
+		assert.Equal(t, Sampled, first)
+		// The policy budget is exhausted, so the trace shares what is left of the global limit
+		assert.Equal(t, SecondChance, secondTrace)
+		return
+	}
+	t.Fatal("could not evaluate the traces within a single second")
 }
 
 func TestOnLateArrivingSpans_RateLimiter(t *testing.T) {
@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+)
+
+func newRemoteParentFilter(remoteParent bool) *policyEvaluator {
+	return &policyEvaluator{
+		logger:            zap.NewNop(),
+		remoteParent:      &remoteParent,
+		maxSpansPerSecond: math.MaxInt64,
+	}
+}
+
+func TestRemoteParentFilter(t *testing.T) {
+	cases := []struct {
+		Desc   string
+		Spans  []testSpan
+		Origin traceOrigin
+	}{
+		{
+			Desc: "internally initiated",
+			Spans: []testSpan{
+				{id: 1, kind: pdata.SpanKindSERVER},
+				{id: 2, parentID: 1, kind: pdata.SpanKindCLIENT},
+				{id: 3, parentID: 2, kind: pdata.SpanKindSERVER},
+			},
+			Origin: originInternal,
+		},
+		{
+			Desc: "externally initiated",
+			Spans: []testSpan{
+				{id: 2, parentID: 1, kind: pdata.SpanKindSERVER},
+				{id: 3, parentID: 2, kind: pdata.SpanKindINTERNAL},
+			},
+			Origin: originExternal,
+		},
+		{
+			Desc: "externally initiated consumer",
+			Spans: []testSpan{
+				{id: 2, parentID: 1, kind: pdata.SpanKindCONSUMER},
+			},
+			Origin: originExternal,
+		},
+		{
+			Desc: "root not present yet",
+			Spans: []testSpan{
+				{id: 2, parentID: 1, kind: pdata.SpanKindINTERNAL},
+				{id: 3, parentID: 2, kind: pdata.SpanKindSERVER},
+			},
+			Origin: originUnknown,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			trace := newTraceWithSpans(c.Spans)
+			traceID := pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+
+			externalDecision := newRemoteParentFilter(true).Evaluate(traceID, trace)
+			internalDecision := newRemoteParentFilter(false).Evaluate(traceID, trace)
+
+			switch c.Origin {
+			case originExternal:
+				assert.Equal(t, Sampled, externalDecision)
+				assert.Equal(t, NotSampled, internalDecision)
+			case originInternal:
+				assert.Equal(t, NotSampled, externalDecision)
+				assert.Equal(t, Sampled, internalDecision)
+			default:
+				assert.Equal(t, NotSampled, externalDecision)
+				assert.Equal(t, NotSampled, internalDecision)
+			}
+		})
+	}
+}
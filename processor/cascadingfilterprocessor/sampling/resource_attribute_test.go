@@ -0,0 +1,95 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func TestResourceAttributeFilter(t *testing.T) {
+	filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:           "checkout",
+		SpansPerSecond: math.MaxInt64,
+		ResourceAttributeCfg: &config.ResourceAttributeCfg{
+			Key:    "service.name",
+			Values: []string{"checkout", "payment"},
+		},
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		Desc          string
+		ResourceAttrs map[string]pdata.AttributeValue
+		SpanAttrs     map[string]pdata.AttributeValue
+		Decision      Decision
+	}{
+		{
+			Desc:          "matching resource attribute",
+			ResourceAttrs: map[string]pdata.AttributeValue{"service.name": pdata.NewAttributeValueString("payment")},
+			Decision:      Sampled,
+		},
+		{
+			Desc:          "nonmatching resource attribute",
+			ResourceAttrs: map[string]pdata.AttributeValue{"service.name": pdata.NewAttributeValueString("frontend")},
+			Decision:      NotSampled,
+		},
+		{
+			Desc:          "matching span attribute only",
+			ResourceAttrs: map[string]pdata.AttributeValue{"service.name": pdata.NewAttributeValueString("frontend")},
+			SpanAttrs:     map[string]pdata.AttributeValue{"service.name": pdata.NewAttributeValueString("checkout")},
+			Decision:      NotSampled,
+		},
+		{
+			Desc:          "missing resource attribute",
+			ResourceAttrs: map[string]pdata.AttributeValue{},
+			Decision:      NotSampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), newTraceCrossFieldAttrs(c.ResourceAttrs, c.SpanAttrs))
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestResourceAttributeFilterOfAnyResource(t *testing.T) {
+	filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:                 "checkout",
+		SpansPerSecond:       math.MaxInt64,
+		ResourceAttributeCfg: &config.ResourceAttributeCfg{Key: "service.name", Values: []string{"checkout"}},
+	})
+	require.NoError(t, err)
+
+	trace := newTraceWithResourceAttributeValues("service.name", []string{"frontend", "checkout", "cart"})
+	assert.Equal(t, Sampled, filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), trace))
+}
+
+func TestResourceAttributeRequiresKey(t *testing.T) {
+	_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:                 "checkout",
+		ResourceAttributeCfg: &config.ResourceAttributeCfg{Values: []string{"checkout"}},
+	})
+	assert.Error(t, err)
+}
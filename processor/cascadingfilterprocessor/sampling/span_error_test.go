@@ -0,0 +1,166 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func newErrorFilter(childErrorOnly bool) *policyEvaluator {
+	return &policyEvaluator{
+		logger:            zap.NewNop(),
+		spanError:         &errorFilter{childErrorOnly: childErrorOnly},
+		maxSpansPerSecond: math.MaxInt64,
+	}
+}
+
+func TestErrorFilter(t *testing.T) {
+	cases := []struct {
+		Desc                   string
+		Spans                  []testSpan
+		Decision               Decision
+		ChildErrorOnlyDecision Decision
+	}{
+		{
+			Desc:                   "no errors",
+			Spans:                  []testSpan{{id: 1}, {id: 2, parentID: 1}},
+			Decision:               NotSampled,
+			ChildErrorOnlyDecision: NotSampled,
+		},
+		{
+			Desc:                   "root error",
+			Spans:                  []testSpan{{id: 1, status: pdata.StatusCodeError}, {id: 2, parentID: 1}},
+			Decision:               Sampled,
+			ChildErrorOnlyDecision: NotSampled,
+		},
+		{
+			Desc:                   "child error",
+			Spans:                  []testSpan{{id: 1}, {id: 2, parentID: 1, status: pdata.StatusCodeError}},
+			Decision:               Sampled,
+			ChildErrorOnlyDecision: Sampled,
+		},
+		{
+			Desc:                   "root and child error",
+			Spans:                  []testSpan{{id: 1, status: pdata.StatusCodeError}, {id: 2, parentID: 1, status: pdata.StatusCodeError}},
+			Decision:               Sampled,
+			ChildErrorOnlyDecision: Sampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			traceID := pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+			assert.Equal(t, c.Decision, newErrorFilter(false).Evaluate(traceID, newTraceWithSpans(c.Spans)))
+			assert.Equal(t, c.ChildErrorOnlyDecision, newErrorFilter(true).Evaluate(traceID, newTraceWithSpans(c.Spans)))
+		})
+	}
+}
+
+func TestMinErrorSpanRatio(t *testing.T) {
+	minErrorRatio := 0.5
+	filter := &policyEvaluator{
+		logger:            zap.NewNop(),
+		minErrorRatio:     &minErrorRatio,
+		maxSpansPerSecond: math.MaxInt64,
+	}
+
+	cases := []struct {
+		Desc     string
+		Errors   int
+		Decision Decision
+	}{
+		{Desc: "no errors", Errors: 0, Decision: NotSampled},
+		{Desc: "below ratio", Errors: 1, Decision: NotSampled},
+		{Desc: "at ratio", Errors: 2, Decision: Sampled},
+		{Desc: "above ratio", Errors: 3, Decision: Sampled},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			spans := make([]testSpan, 4)
+			for i := range spans {
+				spans[i] = testSpan{id: byte(i + 1)}
+				if i < c.Errors {
+					spans[i].status = pdata.StatusCodeError
+				}
+			}
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), newTraceWithSpans(spans))
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestMinErrorSpanRatioValidation(t *testing.T) {
+	for _, ratio := range []float64{-0.1, 1.1, math.NaN()} {
+		invalidRatio := ratio
+		_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+			Name:          "error-ratio",
+			PropertiesCfg: config.PropertiesCfg{MinErrorSpanRatio: &invalidRatio},
+		})
+		assert.Error(t, err, "ratio %v should be rejected", ratio)
+	}
+}
+
+func TestMinNumberOfErrors(t *testing.T) {
+	minNumberOfErrors := 2
+	filter := &policyEvaluator{
+		logger:            zap.NewNop(),
+		minNumberOfErrors: &minNumberOfErrors,
+		maxSpansPerSecond: math.MaxInt64,
+	}
+
+	cases := []struct {
+		Desc     string
+		Errors   int
+		Decision Decision
+	}{
+		{Desc: "no errors", Errors: 0, Decision: NotSampled},
+		{Desc: "below threshold", Errors: 1, Decision: NotSampled},
+		{Desc: "at threshold", Errors: 2, Decision: Sampled},
+		{Desc: "above threshold", Errors: 3, Decision: Sampled},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			spans := make([]testSpan, 10)
+			for i := range spans {
+				spans[i] = testSpan{id: byte(i + 1)}
+				if i < c.Errors {
+					spans[i].status = pdata.StatusCodeError
+				}
+			}
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), newTraceWithSpans(spans))
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestMinNumberOfErrorsValidation(t *testing.T) {
+	for _, count := range []int{0, -1} {
+		invalidCount := count
+		_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+			Name:          "error-count",
+			PropertiesCfg: config.PropertiesCfg{MinNumberOfErrors: &invalidCount},
+		})
+		assert.Error(t, err, "count %v should be rejected", count)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func TestSpanKindsFilter(t *testing.T) {
+	entrypointKinds := []string{"SERVER", "CONSUMER"}
+
+	cases := []struct {
+		Desc      string
+		SpanKinds []string
+		Spans     []testSpan
+		Decision  Decision
+	}{
+		{
+			Desc:      "trace with server span",
+			SpanKinds: entrypointKinds,
+			Spans: []testSpan{
+				{id: 1, kind: pdata.SpanKindSERVER},
+				{id: 2, parentID: 1, kind: pdata.SpanKindCLIENT},
+			},
+			Decision: Sampled,
+		},
+		{
+			Desc:      "trace with consumer span",
+			SpanKinds: entrypointKinds,
+			Spans: []testSpan{
+				{id: 1, kind: pdata.SpanKindINTERNAL},
+				{id: 2, parentID: 1, kind: pdata.SpanKindCONSUMER},
+			},
+			Decision: Sampled,
+		},
+		{
+			Desc:      "trace without entrypoint span",
+			SpanKinds: entrypointKinds,
+			Spans: []testSpan{
+				{id: 1, kind: pdata.SpanKindINTERNAL},
+				{id: 2, parentID: 1, kind: pdata.SpanKindCLIENT},
+			},
+			Decision: NotSampled,
+		},
+		{
+			Desc:      "empty list matching any trace",
+			SpanKinds: []string{},
+			Spans: []testSpan{
+				{id: 1, kind: pdata.SpanKindINTERNAL},
+			},
+			Decision: Sampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+				Name:           "span-kinds",
+				SpansPerSecond: math.MaxInt64,
+				PropertiesCfg:  config.PropertiesCfg{SpanKinds: c.SpanKinds},
+			})
+			require.NoError(t, err)
+
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), newTraceWithSpans(c.Spans))
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestSpanKindsValidation(t *testing.T) {
+	_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:          "span-kinds",
+		PropertiesCfg: config.PropertiesCfg{SpanKinds: []string{"SERVER", "ENTRYPOINT"}},
+	})
+	assert.Error(t, err)
+}
@@ -32,12 +32,12 @@ var (
 	minNumberOfSpans     = 2
 )
 
-func newSpanPropertiesFilter(operationNamePattern *string, minDuration *time.Duration, minNumberOfSpans *int) policyEvaluator {
+func newSpanPropertiesFilter(operationNamePattern *string, minDuration *time.Duration, minNumberOfSpans *int) *policyEvaluator {
 	var operationRe *regexp.Regexp
 	if operationNamePattern != nil {
 		operationRe, _ = regexp.Compile(*operationNamePattern)
 	}
-	return policyEvaluator{
+	return &policyEvaluator{
 		logger:            zap.NewNop(),
 		operationRe:       operationRe,
 		minNumberOfSpans:  minNumberOfSpans,
@@ -46,7 +46,7 @@ func newSpanPropertiesFilter(operationNamePattern *string, minDuration *time.Dur
 	}
 }
 
-func evaluate(t *testing.T, evaluator policyEvaluator, traces *TraceData, expectedDecision Decision) {
+func evaluate(t *testing.T, evaluator *policyEvaluator, traces *TraceData, expectedDecision Decision) {
 	u, _ := uuid.NewRandom()
 	decision := evaluator.Evaluate(pdata.NewTraceID(u), traces)
 	assert.Equal(t, expectedDecision, decision)
@@ -59,7 +59,7 @@ func TestPartialSpanPropertiesFilter(t *testing.T) {
 
 	cases := []struct {
 		Desc      string
-		Evaluator policyEvaluator
+		Evaluator *policyEvaluator
 	}{
 		{
 			Desc:      "operation name filter",
@@ -136,6 +136,50 @@ func TestSpanPropertiesFilter(t *testing.T) {
 	}
 }
 
+func TestMinDurationIsTraceDuration(t *testing.T) {
+	filter := newSpanPropertiesFilter(nil, &minDuration, nil)
+
+	cases := []struct {
+		Desc     string
+		Spans    []testSpan
+		Decision Decision
+	}{
+		{
+			Desc:     "single span",
+			Spans:    []testSpan{{id: 1, duration: minDuration}},
+			Decision: Sampled,
+		},
+		{
+			Desc:     "single short span",
+			Spans:    []testSpan{{id: 1, duration: minDuration / 2}},
+			Decision: NotSampled,
+		},
+		{
+			// None of the spans is long enough, but the whole trace is
+			Desc: "sequential short spans",
+			Spans: []testSpan{
+				{id: 1, duration: minDuration / 2},
+				{id: 2, start: minDuration / 2, duration: minDuration / 2},
+			},
+			Decision: Sampled,
+		},
+		{
+			Desc: "overlapping short spans",
+			Spans: []testSpan{
+				{id: 1, duration: minDuration / 2},
+				{id: 2, start: minDuration / 4, duration: minDuration / 4},
+			},
+			Decision: NotSampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			evaluate(t, filter, newTraceWithSpans(c.Spans), c.Decision)
+		})
+	}
+}
+
 func newTraceAttrs(operationName string, duration time.Duration, numberOfSpans int) *TraceData {
 	endTs := time.Now().UnixNano()
 	startTs := endTs - duration.Nanoseconds()
@@ -0,0 +1,111 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func newStatusCodeFilter(t *testing.T, statusCodes []string, minNumberOfSpans *int) PolicyEvaluator {
+	filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:           "status-code",
+		SpansPerSecond: math.MaxInt64,
+		StatusCodeCfg:  &config.StatusCodeCfg{StatusCodes: statusCodes},
+		PropertiesCfg:  config.PropertiesCfg{MinNumberOfSpans: minNumberOfSpans},
+	})
+	require.NoError(t, err)
+	return filter
+}
+
+func TestStatusCodeFilter(t *testing.T) {
+	minSpans := 3
+
+	cases := []struct {
+		Desc             string
+		StatusCodes      []string
+		MinNumberOfSpans *int
+		Spans            []testSpan
+		Decision         Decision
+	}{
+		{
+			Desc:        "mixed statuses with error",
+			StatusCodes: []string{"ERROR"},
+			Spans:       []testSpan{{id: 1, status: pdata.StatusCodeOk}, {id: 2, status: pdata.StatusCodeError}, {id: 3, status: pdata.StatusCodeUnset}},
+			Decision:    Sampled,
+		},
+		{
+			Desc:        "mixed statuses without error",
+			StatusCodes: []string{"ERROR"},
+			Spans:       []testSpan{{id: 1, status: pdata.StatusCodeOk}, {id: 2, status: pdata.StatusCodeUnset}},
+			Decision:    NotSampled,
+		},
+		{
+			Desc:        "any of multiple statuses",
+			StatusCodes: []string{"ERROR", "UNSET"},
+			Spans:       []testSpan{{id: 1, status: pdata.StatusCodeOk}, {id: 2, status: pdata.StatusCodeUnset}},
+			Decision:    Sampled,
+		},
+		{
+			Desc:        "only ok statuses",
+			StatusCodes: []string{"ERROR", "UNSET"},
+			Spans:       []testSpan{{id: 1, status: pdata.StatusCodeOk}, {id: 2, status: pdata.StatusCodeOk}},
+			Decision:    NotSampled,
+		},
+		{
+			Desc:             "matching status and span count",
+			StatusCodes:      []string{"ERROR"},
+			MinNumberOfSpans: &minSpans,
+			Spans:            []testSpan{{id: 1, status: pdata.StatusCodeOk}, {id: 2, status: pdata.StatusCodeError}, {id: 3, status: pdata.StatusCodeOk}},
+			Decision:         Sampled,
+		},
+		{
+			Desc:             "matching status but not span count",
+			StatusCodes:      []string{"ERROR"},
+			MinNumberOfSpans: &minSpans,
+			Spans:            []testSpan{{id: 1, status: pdata.StatusCodeOk}, {id: 2, status: pdata.StatusCodeError}},
+			Decision:         NotSampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			filter := newStatusCodeFilter(t, c.StatusCodes, c.MinNumberOfSpans)
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), newTraceWithSpans(c.Spans))
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestStatusCodeValidation(t *testing.T) {
+	_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:          "status-code",
+		StatusCodeCfg: &config.StatusCodeCfg{},
+	})
+	assert.Error(t, err)
+
+	_, err = NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:          "status-code",
+		StatusCodeCfg: &config.StatusCodeCfg{StatusCodes: []string{"ERROR", "FAILED"}},
+	})
+	assert.Error(t, err)
+}
@@ -19,8 +19,11 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/consumer/pdata"
 	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
 )
 
 func newStringAttributeFilter() *policyEvaluator {
@@ -84,6 +87,135 @@ func TestStringTagFilter(t *testing.T) {
 	}
 }
 
+func TestInvertedStringTagFilter(t *testing.T) {
+	namePattern := "GET.*"
+
+	cases := []struct {
+		Desc        string
+		NamePattern *string
+		Trace       *TraceData
+		Decision    Decision
+	}{
+		{
+			Desc:     "health check span",
+			Trace:    newTraceStringAttrs(map[string]pdata.AttributeValue{}, "http.target", "/health"),
+			Decision: NotSampled,
+		},
+		{
+			Desc:     "health check resource",
+			Trace:    newTraceStringAttrs(map[string]pdata.AttributeValue{"http.target": pdata.NewAttributeValueString("/health")}, "", ""),
+			Decision: NotSampled,
+		},
+		{
+			Desc:     "other target",
+			Trace:    newTraceStringAttrs(map[string]pdata.AttributeValue{}, "http.target", "/orders"),
+			Decision: Sampled,
+		},
+		{
+			Desc:     "missing attribute",
+			Trace:    newTraceStringAttrs(map[string]pdata.AttributeValue{}, "", ""),
+			Decision: Sampled,
+		},
+		{
+			Desc:        "other target and matching name",
+			NamePattern: &namePattern,
+			Trace:       withSpanName(newTraceStringAttrs(map[string]pdata.AttributeValue{}, "http.target", "/orders"), "GET /orders"),
+			Decision:    Sampled,
+		},
+		{
+			Desc:        "other target and nonmatching name",
+			NamePattern: &namePattern,
+			Trace:       withSpanName(newTraceStringAttrs(map[string]pdata.AttributeValue{}, "http.target", "/orders"), "POST /orders"),
+			Decision:    NotSampled,
+		},
+		{
+			Desc:        "health check and matching name",
+			NamePattern: &namePattern,
+			Trace:       withSpanName(newTraceStringAttrs(map[string]pdata.AttributeValue{}, "http.target", "/health"), "GET /health"),
+			Decision:    NotSampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+				Name:           "not-health-check",
+				SpansPerSecond: math.MaxInt64,
+				StringAttributeCfg: &config.StringAttributeCfg{
+					Key:         "http.target",
+					Values:      []string{"/health"},
+					InvertMatch: true,
+				},
+				PropertiesCfg: config.PropertiesCfg{NamePattern: c.NamePattern},
+			})
+			require.NoError(t, err)
+
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), c.Trace)
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestStringTagFilterValuePatterns(t *testing.T) {
+	filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:           "customers",
+		SpansPerSecond: math.MaxInt64,
+		StringAttributeCfg: &config.StringAttributeCfg{
+			Key:           "customer",
+			Values:        []string{"internal"},
+			ValuePatterns: []string{"^customer-.*", "^partner-[0-9]+$"},
+		},
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		Desc     string
+		Value    string
+		Decision Decision
+	}{
+		{Desc: "exact value", Value: "internal", Decision: Sampled},
+		{Desc: "matching first pattern", Value: "customer-acme", Decision: Sampled},
+		{Desc: "matching second pattern", Value: "partner-42", Decision: Sampled},
+		{Desc: "nonmatching value", Value: "partner-acme", Decision: NotSampled},
+		{Desc: "empty value", Value: "", Decision: NotSampled},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), newTraceStringAttrs(map[string]pdata.AttributeValue{}, "customer", c.Value))
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestStringTagFilterInvalidValuePattern(t *testing.T) {
+	_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name: "customers",
+		StringAttributeCfg: &config.StringAttributeCfg{
+			Key:           "customer",
+			ValuePatterns: []string{"customer-(.*"},
+		},
+	})
+	assert.Error(t, err)
+}
+
+// withSpanName sets the name of all spans of the trace
+func withSpanName(trace *TraceData, name string) *TraceData {
+	for _, batch := range trace.ReceivedBatches {
+		rs := batch.ResourceSpans()
+		for i := 0; i < rs.Len(); i++ {
+			ils := rs.At(i).InstrumentationLibrarySpans()
+			for j := 0; j < ils.Len(); j++ {
+				spans := ils.At(j).Spans()
+				for k := 0; k < spans.Len(); k++ {
+					spans.At(k).SetName(name)
+				}
+			}
+		}
+	}
+	return trace
+}
+
 func newTraceStringAttrs(nodeAttrs map[string]pdata.AttributeValue, spanAttrKey string, spanAttrValue string) *TraceData {
 	var traceBatches []pdata.Traces
 	traces := pdata.NewTraces()
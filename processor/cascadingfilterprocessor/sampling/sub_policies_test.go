@@ -0,0 +1,189 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func TestNestedSubPolicies(t *testing.T) {
+	// (latency > 2s AND service = checkout) OR error = true
+	filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:           "composite",
+		SpansPerSecond: math.MaxInt64,
+		Operator:       "or",
+		SubPolicies: []config.SubPolicyCfg{
+			{
+				PolicyCriteriaCfg: config.PolicyCriteriaCfg{Name: "slow-checkout"},
+				Operator:          "and",
+				SubPolicies: []config.PolicyCriteriaCfg{
+					{Name: "slow", NumericAttributeCfg: &config.NumericAttributeCfg{Key: "latency_ms", MinValue: 2001, MaxValue: math.MaxInt64}},
+					{Name: "checkout", StringAttributeCfg: &config.StringAttributeCfg{Key: "service", Values: []string{"checkout"}}},
+				},
+			},
+			{PolicyCriteriaCfg: config.PolicyCriteriaCfg{Name: "error", BooleanAttributeCfg: &config.BooleanAttributeCfg{Key: "error", Value: true}}},
+		},
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		Desc      string
+		SpanAttrs map[string]pdata.AttributeValue
+		Decision  Decision
+	}{
+		{
+			Desc: "slow checkout",
+			SpanAttrs: map[string]pdata.AttributeValue{
+				"latency_ms": pdata.NewAttributeValueInt(3000),
+				"service":    pdata.NewAttributeValueString("checkout"),
+			},
+			Decision: Sampled,
+		},
+		{
+			Desc: "fast checkout",
+			SpanAttrs: map[string]pdata.AttributeValue{
+				"latency_ms": pdata.NewAttributeValueInt(100),
+				"service":    pdata.NewAttributeValueString("checkout"),
+			},
+			Decision: NotSampled,
+		},
+		{
+			Desc: "slow other service",
+			SpanAttrs: map[string]pdata.AttributeValue{
+				"latency_ms": pdata.NewAttributeValueInt(3000),
+				"service":    pdata.NewAttributeValueString("cart"),
+			},
+			Decision: NotSampled,
+		},
+		{
+			Desc: "error in other service",
+			SpanAttrs: map[string]pdata.AttributeValue{
+				"service": pdata.NewAttributeValueString("cart"),
+				"error":   pdata.NewAttributeValueBool(true),
+			},
+			Decision: Sampled,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			decision := filter.Evaluate(pdata.NewTraceID([16]byte{1}), newTraceCrossFieldAttrs(map[string]pdata.AttributeValue{}, c.SpanAttrs))
+			assert.Equal(t, c.Decision, decision)
+		})
+	}
+}
+
+func TestSubPoliciesCombinedWithPolicyCriteria(t *testing.T) {
+	// The sub-policies are AND-ed by default and must be met along with the criteria of the policy itself
+	filter, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:               "combined",
+		SpansPerSecond:     math.MaxInt64,
+		StringAttributeCfg: &config.StringAttributeCfg{Key: "service", Values: []string{"checkout"}},
+		SubPolicies: []config.SubPolicyCfg{
+			{PolicyCriteriaCfg: config.PolicyCriteriaCfg{Name: "error", BooleanAttributeCfg: &config.BooleanAttributeCfg{Key: "error", Value: true}}},
+			{PolicyCriteriaCfg: config.PolicyCriteriaCfg{Name: "not-synthetic", StringAttributeCfg: &config.StringAttributeCfg{Key: "synthetic", Values: []string{"true"}}, InvertMatch: true}},
+		},
+	})
+	require.NoError(t, err)
+
+	matching := map[string]pdata.AttributeValue{
+		"service": pdata.NewAttributeValueString("checkout"),
+		"error":   pdata.NewAttributeValueBool(true),
+	}
+	assert.Equal(t, Sampled, filter.Evaluate(pdata.NewTraceID([16]byte{1}), newTraceCrossFieldAttrs(map[string]pdata.AttributeValue{}, matching)))
+
+	synthetic := map[string]pdata.AttributeValue{
+		"service":   pdata.NewAttributeValueString("checkout"),
+		"error":     pdata.NewAttributeValueBool(true),
+		"synthetic": pdata.NewAttributeValueString("true"),
+	}
+	assert.Equal(t, NotSampled, filter.Evaluate(pdata.NewTraceID([16]byte{1}), newTraceCrossFieldAttrs(map[string]pdata.AttributeValue{}, synthetic)))
+
+	otherService := map[string]pdata.AttributeValue{
+		"service": pdata.NewAttributeValueString("cart"),
+		"error":   pdata.NewAttributeValueBool(true),
+	}
+	assert.Equal(t, NotSampled, filter.Evaluate(pdata.NewTraceID([16]byte{1}), newTraceCrossFieldAttrs(map[string]pdata.AttributeValue{}, otherService)))
+}
+
+func TestSubPoliciesValidation(t *testing.T) {
+	_, err := NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name:        "unknown-operator",
+		Operator:    "xor",
+		SubPolicies: []config.SubPolicyCfg{{PolicyCriteriaCfg: config.PolicyCriteriaCfg{Name: "any"}}},
+	})
+	assert.Error(t, err)
+
+	_, err = NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name: "invalid-nested",
+		SubPolicies: []config.SubPolicyCfg{
+			{
+				PolicyCriteriaCfg: config.PolicyCriteriaCfg{Name: "nested"},
+				SubPolicies:       []config.PolicyCriteriaCfg{{Name: "invalid", BooleanAttributeCfg: &config.BooleanAttributeCfg{}}},
+			},
+		},
+	})
+	assert.Error(t, err)
+
+	_, err = NewFilter(zap.NewNop(), &config.PolicyCfg{
+		Name: "unknown-nested-operator",
+		SubPolicies: []config.SubPolicyCfg{
+			{
+				PolicyCriteriaCfg: config.PolicyCriteriaCfg{Name: "nested"},
+				Operator:          "xor",
+				SubPolicies:       []config.PolicyCriteriaCfg{{Name: "any"}},
+			},
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestCriteriaPolicyCfgCopiesAllCriteria(t *testing.T) {
+	// Each of the criteria is set to a non-zero value, so any of them not copied to the policy config is noticed
+	var criteria config.PolicyCriteriaCfg
+	criteriaValue := reflect.ValueOf(&criteria).Elem()
+	for i := 0; i < criteriaValue.NumField(); i++ {
+		field := criteriaValue.Field(i)
+		switch field.Kind() {
+		case reflect.Ptr:
+			field.Set(reflect.New(field.Type().Elem()))
+		case reflect.String:
+			field.SetString("criteria")
+		case reflect.Bool:
+			field.SetBool(true)
+		case reflect.Struct:
+			minDuration := time.Second
+			field.Set(reflect.ValueOf(config.PropertiesCfg{MinDuration: &minDuration}))
+		default:
+			t.Fatalf("unexpected kind of field %s", criteriaValue.Type().Field(i).Name)
+		}
+	}
+
+	policyValue := reflect.ValueOf(criteriaPolicyCfg(criteria))
+	for i := 0; i < criteriaValue.NumField(); i++ {
+		name := criteriaValue.Type().Field(i).Name
+		assert.Equal(t, criteriaValue.Field(i).Interface(), policyValue.FieldByName(name).Interface(), "field %s", name)
+	}
+}
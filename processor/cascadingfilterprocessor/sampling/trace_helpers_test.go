@@ -0,0 +1,61 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+// testSpan describes a span of the trace built by newTraceWithSpans, zero values are left unset
+type testSpan struct {
+	id, parentID byte
+	kind         pdata.SpanKind
+	status       pdata.StatusCode
+	// duration, when set, makes the span start at testSpanStartTime (shifted by the start offset) and end after it
+	start, duration time.Duration
+}
+
+var testSpanStartTime = time.Unix(1000, 0)
+
+// newTraceWithSpans builds a trace with a single batch having the given spans
+func newTraceWithSpans(testSpans []testSpan) *TraceData {
+	traces := pdata.NewTraces()
+	traces.ResourceSpans().Resize(1)
+	rs := traces.ResourceSpans().At(0)
+	rs.InstrumentationLibrarySpans().Resize(1)
+	ils := rs.InstrumentationLibrarySpans().At(0)
+	ils.Spans().Resize(len(testSpans))
+	for i, ts := range testSpans {
+		span := ils.Spans().At(i)
+		span.SetTraceID(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+		span.SetSpanID(pdata.NewSpanID([8]byte{ts.id}))
+		if ts.parentID != 0 {
+			span.SetParentSpanID(pdata.NewSpanID([8]byte{ts.parentID}))
+		}
+		span.SetKind(ts.kind)
+		span.Status().SetCode(ts.status)
+		if ts.duration != 0 {
+			startTime := testSpanStartTime.Add(ts.start)
+			span.SetStartTime(pdata.TimestampUnixNano(startTime.UnixNano()))
+			span.SetEndTime(pdata.TimestampUnixNano(startTime.Add(ts.duration).UnixNano()))
+		}
+	}
+	return &TraceData{
+		ReceivedBatches: []pdata.Traces{traces},
+		SpanCount:       int64(len(testSpans)),
+	}
+}
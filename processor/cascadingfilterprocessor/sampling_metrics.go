@@ -0,0 +1,125 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configmodels"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMetricsEmitInterval = time.Minute
+
+	metricTracesName         = "cascading_filter.traces"
+	metricPolicyDecisionName = "cascading_filter.policy_decisions"
+
+	labelPolicy   = "policy"
+	labelDecision = "decision"
+
+	decisionSampledValue      = "sampled"
+	decisionNotSampledValue   = "not_sampled"
+	decisionEvaluatedValue    = "evaluated"
+	decisionSecondChanceValue = "second_chance"
+	decisionDropValue         = "drop"
+	decisionErrorValue        = "error"
+)
+
+// findMetricsExporter looks up the metrics exporter with given name among the exporters available in the host
+func findMetricsExporter(host component.Host, name string) (consumer.MetricsConsumer, error) {
+	for exporterCfg, exporter := range host.GetExporters()[configmodels.MetricsDataType] {
+		if exporterCfg.Name() != name {
+			continue
+		}
+		metricsExporter, ok := exporter.(component.MetricsExporter)
+		if !ok {
+			return nil, fmt.Errorf("%s exporter is not a metrics exporter", name)
+		}
+		return metricsExporter, nil
+	}
+	return nil, fmt.Errorf("metrics exporter %s is not configured in any metrics pipeline", name)
+}
+
+// emitSamplingMetrics sends the sampling statistics accumulated since start to the metrics consumer
+func (cfsp *cascadingFilterSpanProcessor) emitSamplingMetrics() {
+	md := cfsp.buildSamplingMetrics(cfsp.metricsStartTime, pdata.TimestampUnixNano(time.Now().UnixNano()))
+	if err := cfsp.metricsConsumer.ConsumeMetrics(cfsp.ctx, md); err != nil {
+		cfsp.logger.Warn("Error sending sampling metrics", zap.Error(err))
+	}
+}
+
+func (cfsp *cascadingFilterSpanProcessor) buildSamplingMetrics(startTime, timestamp pdata.TimestampUnixNano) pdata.Metrics {
+	md := pdata.NewMetrics()
+	md.ResourceMetrics().Resize(1)
+	rm := md.ResourceMetrics().At(0)
+	rm.InstrumentationLibraryMetrics().Resize(1)
+	ilm := rm.InstrumentationLibraryMetrics().At(0)
+	ilm.InstrumentationLibrary().SetName(typeStr)
+	ilm.Metrics().Resize(2)
+
+	traces := ilm.Metrics().At(0)
+	initCumulativeSum(traces, metricTracesName, "Count of traces by the final decision")
+	appendDataPoint(traces, startTime, timestamp, atomic.LoadInt64(&cfsp.sampledTracesCount), labelDecision, decisionSampledValue)
+	appendDataPoint(traces, startTime, timestamp, atomic.LoadInt64(&cfsp.notSampledTracesCount), labelDecision, decisionNotSampledValue)
+
+	policyDecisions := ilm.Metrics().At(1)
+	initCumulativeSum(policyDecisions, metricPolicyDecisionName, "Count of provisional (policy) decisions")
+	policyStats := cfsp.PolicyStats()
+	policyNames := make([]string, 0, len(policyStats))
+	for name := range policyStats {
+		policyNames = append(policyNames, name)
+	}
+	sort.Strings(policyNames)
+	for _, name := range policyNames {
+		stat := policyStats[name]
+		appendDataPoint(policyDecisions, startTime, timestamp, stat.Evaluated, labelPolicy, name, labelDecision, decisionEvaluatedValue)
+		appendDataPoint(policyDecisions, startTime, timestamp, stat.Sampled, labelPolicy, name, labelDecision, decisionSampledValue)
+		appendDataPoint(policyDecisions, startTime, timestamp, stat.NotSampled, labelPolicy, name, labelDecision, decisionNotSampledValue)
+		appendDataPoint(policyDecisions, startTime, timestamp, stat.SecondChance, labelPolicy, name, labelDecision, decisionSecondChanceValue)
+		appendDataPoint(policyDecisions, startTime, timestamp, stat.Drop, labelPolicy, name, labelDecision, decisionDropValue)
+		appendDataPoint(policyDecisions, startTime, timestamp, stat.Errors, labelPolicy, name, labelDecision, decisionErrorValue)
+	}
+
+	return md
+}
+
+func initCumulativeSum(metric pdata.Metric, name string, description string) {
+	metric.SetName(name)
+	metric.SetDescription(description)
+	metric.SetUnit("1")
+	metric.SetDataType(pdata.MetricDataTypeIntSum)
+	metric.IntSum().SetIsMonotonic(true)
+	metric.IntSum().SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+}
+
+// appendDataPoint adds a data point with the value and labels, provided as key-value pairs
+func appendDataPoint(metric pdata.Metric, startTime, timestamp pdata.TimestampUnixNano, value int64, labels ...string) {
+	dps := metric.IntSum().DataPoints()
+	dps.Resize(dps.Len() + 1)
+	dp := dps.At(dps.Len() - 1)
+	dp.SetStartTime(startTime)
+	dp.SetTimestamp(timestamp)
+	dp.SetValue(value)
+	for i := 0; i+1 < len(labels); i += 2 {
+		dp.LabelsMap().Insert(labels[i], labels[i+1])
+	}
+}
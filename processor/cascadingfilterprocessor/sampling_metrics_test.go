@@ -0,0 +1,111 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+)
+
+func TestEmitSamplingMetrics(t *testing.T) {
+	sink := new(consumertest.MetricsSink)
+	cfsp := &cascadingFilterSpanProcessor{
+		ctx:    context.Background(),
+		logger: zap.NewNop(),
+		policies: []*Policy{
+			{Name: "policy-b", evaluatedCount: 10, sampledCount: 4, notSampledCount: 5, evaluationErrorCount: 1},
+			{Name: "policy-a", evaluatedCount: 7, secondChanceCount: 6, dropCount: 1},
+		},
+		sampledTracesCount:    6,
+		notSampledTracesCount: 11,
+		metricsConsumer:       sink,
+	}
+
+	cfsp.emitSamplingMetrics()
+
+	require.Len(t, sink.AllMetrics(), 1)
+	values := collectDataPointValues(sink.AllMetrics()[0])
+
+	require.Equal(t, map[string]int64{
+		"decision=sampled":     6,
+		"decision=not_sampled": 11,
+	}, values[metricTracesName])
+
+	require.Equal(t, map[string]int64{
+		"decision=evaluated,policy=policy-a":     7,
+		"decision=sampled,policy=policy-a":       0,
+		"decision=not_sampled,policy=policy-a":   0,
+		"decision=second_chance,policy=policy-a": 6,
+		"decision=drop,policy=policy-a":          1,
+		"decision=error,policy=policy-a":         0,
+		"decision=evaluated,policy=policy-b":     10,
+		"decision=sampled,policy=policy-b":       4,
+		"decision=not_sampled,policy=policy-b":   5,
+		"decision=second_chance,policy=policy-b": 0,
+		"decision=drop,policy=policy-b":          0,
+		"decision=error,policy=policy-b":         1,
+	}, values[metricPolicyDecisionName])
+}
+
+func TestStartFailsWithoutMetricsExporter(t *testing.T) {
+	cfsp := &cascadingFilterSpanProcessor{
+		logger:              zap.NewNop(),
+		metricsExporterName: "otlp/missing",
+		metricsTicker:       &manualTTicker{},
+	}
+
+	require.Error(t, cfsp.Start(context.Background(), componenttest.NewNopHost()))
+}
+
+// collectDataPointValues returns the values of int sum data points, keyed by metric name and then
+// by the sorted labels
+func collectDataPointValues(md pdata.Metrics) map[string]map[string]int64 {
+	values := make(map[string]map[string]int64)
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).InstrumentationLibraryMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			metrics := ilms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+				metricValues := make(map[string]int64)
+				dps := metric.IntSum().DataPoints()
+				for l := 0; l < dps.Len(); l++ {
+					dp := dps.At(l)
+					metricValues[labelsKey(dp.LabelsMap())] = dp.Value()
+				}
+				values[metric.Name()] = metricValues
+			}
+		}
+	}
+	return values
+}
+
+func labelsKey(labels pdata.StringMap) string {
+	var keys []string
+	labels.ForEach(func(k string, v string) {
+		keys = append(keys, k+"="+v)
+	})
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
@@ -0,0 +1,69 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"time"
+)
+
+const defaultBudgetWindow = time.Hour
+
+// spanBudget is a token bucket holding up to the number of spans allowed in a window. It's refilled gradually
+// over the window, so bursts are allowed as long as the budget of the window is not exceeded. It's not safe
+// for concurrent use.
+type spanBudget struct {
+	capacity float64
+	// refillPerSecond is the number of spans added to the budget each second
+	refillPerSecond float64
+	tokens          float64
+	lastRefill      time.Time
+}
+
+// newSpanBudget creates the budget, which is full at the given time
+func newSpanBudget(spansPerWindow int64, window time.Duration, now time.Time) *spanBudget {
+	return &spanBudget{
+		capacity:        float64(spansPerWindow),
+		refillPerSecond: float64(spansPerWindow) / window.Seconds(),
+		tokens:          float64(spansPerWindow),
+		lastRefill:      now,
+	}
+}
+
+func (b *spanBudget) refill(now time.Time) {
+	if elapsed := now.Sub(b.lastRefill).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.refillPerSecond
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.lastRefill = now
+	}
+}
+
+// take uses the budget for the given number of spans. It returns false (and leaves the budget intact)
+// if there is not enough of it left
+func (b *spanBudget) take(numSpans int64, now time.Time) bool {
+	b.refill(now)
+	if float64(numSpans) > b.tokens {
+		return false
+	}
+	b.tokens -= float64(numSpans)
+	return true
+}
+
+// remaining returns the number of spans which still fit the budget
+func (b *spanBudget) remaining(now time.Time) int64 {
+	b.refill(now)
+	return int64(b.tokens)
+}
@@ -0,0 +1,118 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/processor/cascadingfilterprocessor/config"
+)
+
+func TestSpanBudgetRefill(t *testing.T) {
+	start := time.Unix(1000, 0)
+	budget := newSpanBudget(3600, time.Hour, start)
+
+	// The whole budget might be used at once
+	require.True(t, budget.take(3600, start))
+	require.False(t, budget.take(1, start))
+	require.EqualValues(t, 0, budget.remaining(start))
+
+	// It's refilled gradually, one span per second here
+	require.EqualValues(t, 10, budget.remaining(start.Add(10*time.Second)))
+	require.False(t, budget.take(11, start.Add(10*time.Second)))
+	require.True(t, budget.take(10, start.Add(10*time.Second)))
+
+	// But never above the window budget
+	require.EqualValues(t, 3600, budget.remaining(start.Add(48*time.Hour)))
+}
+
+func TestSpanBudgetAllowsBursts(t *testing.T) {
+	msp := new(consumertest.TracesSink)
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(&Policy{Name: "all-policy", Evaluator: &spanCountPolicyEvaluator{minSpans: 1}, ctx: context.TODO()}),
+		// The per second limit is replaced by the budget of the window
+		withMaxSpansPerSecond(10),
+		func(cfsp *cascadingFilterSpanProcessor) {
+			cfsp.spanBudget = newSpanBudget(100, time.Hour, time.Now())
+		},
+	)
+
+	// The burst of four traces uses the whole budget, so the fifth one is dropped
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(pdata.NewTraceID([16]byte{byte(i)}), 25)))
+	}
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	require.Equal(t, 100, msp.SpansCount())
+	require.Nil(t, findTrace(msp.AllTraces(), pdata.NewTraceID([16]byte{5})))
+}
+
+func TestSpanBudgetValidation(t *testing.T) {
+	cfg := config.Config{
+		DecisionWait:   2 * time.Second,
+		NumTraces:      100,
+		SpansPerWindow: 1000,
+		PolicyCfgs:     []config.PolicyCfg{{Name: "reserved", ReservedBudgetRatio: 0.5}},
+	}
+	_, err := newCascadingFilterSpanProcessor(zap.NewNop(), consumertest.NewTracesNop(), cfg)
+	require.Error(t, err, "reserved budget is not supported along with the window budget")
+
+	cfg.PolicyCfgs = nil
+	cfg.SpansPerWindow = -1
+	_, err = newCascadingFilterSpanProcessor(zap.NewNop(), consumertest.NewTracesNop(), cfg)
+	require.Error(t, err)
+
+	cfg.SpansPerWindow = 1000
+	tsp, err := newCascadingFilterSpanProcessor(zap.NewNop(), consumertest.NewTracesNop(), cfg)
+	require.NoError(t, err)
+	require.NotNil(t, tsp.spanBudget)
+}
+
+func TestProbabilisticFilterSizedByWindowBudget(t *testing.T) {
+	ratio := float32(1.0)
+	cfg := config.Config{
+		DecisionWait:                2 * time.Second,
+		NumTraces:                   100,
+		SpansPerWindow:              7200,
+		BudgetWindow:                time.Hour,
+		ProbabilisticFilteringRatio: &ratio,
+	}
+	cfsp, err := newCascadingFilterSpanProcessor(zap.NewNop(), consumertest.NewTracesNop(), cfg)
+	require.NoError(t, err)
+	require.True(t, cfsp.policies[0].probabilisticFilter)
+
+	// Without spans_per_second, the probabilistic filter gets the average rate of the window, i.e. 2 spans per second
+	msp := new(consumertest.TracesSink)
+	tsp := newTestProcessor(t,
+		withNextConsumer(msp),
+		withPolicies(cfsp.policies[0]),
+	)
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(pdata.NewTraceID([16]byte{1}), 2)))
+	require.NoError(t, tsp.ConsumeTraces(context.Background(), tracesWithSpans(pdata.NewTraceID([16]byte{2}), 2)))
+	tsp.samplingPolicyOnTick()
+	tsp.samplingPolicyOnTick()
+
+	require.Equal(t, 2, msp.SpansCount())
+	require.NotNil(t, findTrace(msp.AllTraces(), pdata.NewTraceID([16]byte{1})))
+}
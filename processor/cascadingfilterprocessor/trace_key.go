@@ -0,0 +1,53 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// String returns the canonical encoding of the trace key, which is a lowercase hex string of its 16 bytes.
+// It must be kept stable, as it is meant to identify traces in storage across collector versions.
+func (tk traceKey) String() string {
+	return hex.EncodeToString(tk[:])
+}
+
+// traceKeyFromString decodes the trace key from its canonical encoding, as returned by traceKey.String.
+func traceKeyFromString(encoded string) (traceKey, error) {
+	var tk traceKey
+	if len(encoded) != hex.EncodedLen(len(tk)) {
+		return tk, fmt.Errorf("invalid trace key length, expected %d hex characters, got %d", hex.EncodedLen(len(tk)), len(encoded))
+	}
+	if _, err := hex.Decode(tk[:], []byte(encoded)); err != nil {
+		return tk, fmt.Errorf("invalid trace key encoding: %w", err)
+	}
+	return tk, nil
+}
+
+// MarshalText implements encoding.TextMarshaler, so the trace key is stored in its canonical encoding.
+func (tk traceKey) MarshalText() ([]byte, error) {
+	return []byte(tk.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, so the stored trace key can be read back.
+func (tk *traceKey) UnmarshalText(text []byte) error {
+	decoded, err := traceKeyFromString(string(text))
+	if err != nil {
+		return err
+	}
+	*tk = decoded
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascadingfilterprocessor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceKeyRoundTrip(t *testing.T) {
+	cases := []traceKey{
+		{},
+		{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+
+	for _, tk := range cases {
+		decoded, err := traceKeyFromString(tk.String())
+		require.NoError(t, err)
+		assert.Equal(t, tk, decoded)
+	}
+}
+
+func TestTraceKeyCanonicalEncoding(t *testing.T) {
+	tk := traceKey{0x0a, 0x1b, 0x2c, 0x3d, 0x4e, 0x5f, 0x60, 0x71, 0x82, 0x93, 0xa4, 0xb5, 0xc6, 0xd7, 0xe8, 0xf9}
+	assert.Equal(t, "0a1b2c3d4e5f60718293a4b5c6d7e8f9", tk.String())
+
+	// Upper case encoding is accepted, though never produced
+	decoded, err := traceKeyFromString("0A1B2C3D4E5F60718293A4B5C6D7E8F9")
+	require.NoError(t, err)
+	assert.Equal(t, tk, decoded)
+}
+
+func TestTraceKeyEncodingDoesNotCollide(t *testing.T) {
+	encoded := make(map[string]traceKey)
+	for i := 0; i < 16; i++ {
+		for v := 1; v < 256; v++ {
+			var tk traceKey
+			tk[i] = byte(v)
+			s := tk.String()
+			existing, found := encoded[s]
+			require.False(t, found, "trace keys %v and %v have the same encoding %q", existing, tk, s)
+			encoded[s] = tk
+		}
+	}
+}
+
+func TestTraceKeyFromInvalidString(t *testing.T) {
+	_, err := traceKeyFromString("0a1b2c")
+	assert.Error(t, err)
+
+	_, err = traceKeyFromString("zz1b2c3d4e5f60718293a4b5c6d7e8f9")
+	assert.Error(t, err)
+
+	// One character too many, which would still decode to 16 bytes
+	_, err = traceKeyFromString("0a1b2c3d4e5f60718293a4b5c6d7e8f90")
+	assert.Error(t, err)
+}
+
+func TestTraceKeyJSONEncoding(t *testing.T) {
+	tk := traceKey{0x0a, 0x1b, 0x2c, 0x3d, 0x4e, 0x5f, 0x60, 0x71, 0x82, 0x93, 0xa4, 0xb5, 0xc6, 0xd7, 0xe8, 0xf9}
+	encoded, err := json.Marshal(decisionRecord{TraceID: tk})
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"trace_id":"0a1b2c3d4e5f60718293a4b5c6d7e8f9"`)
+
+	var decoded decisionRecord
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, tk, decoded.TraceID)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"trace_id":"0a1b2c"}`), &decoded))
+}
@@ -35,6 +35,10 @@ type Config struct {
 	// See defaultLatencyHistogramBucketsMs in processor.go for the default value.
 	LatencyHistogramBuckets []time.Duration `mapstructure:"latency_histogram_buckets"`
 
+	// ServiceLatencyHistogramBuckets overrides LatencyHistogramBuckets for the services, keyed by their service.name.
+	// Each of the lists must be increasing.
+	ServiceLatencyHistogramBuckets map[string][]time.Duration `mapstructure:"service_latency_histogram_buckets"`
+
 	// Dimensions defines the list of additional dimensions on top of the provided:
 	// - service.name
 	// - operation
@@ -43,4 +47,10 @@ type Config struct {
 	// The dimensions will be fetched from the span's attributes. Examples of some conventionally used attributes:
 	// https://github.com/open-telemetry/opentelemetry-collector/blob/master/translator/conventions/opentelemetry.go.
 	Dimensions []Dimension `mapstructure:"dimensions"`
+
+	// ApdexThreshold is the latency threshold (T) of satisfied requests, used to classify requests for the Apdex metric:
+	// satisfied (up to T), tolerating (up to 4T) and frustrated (above 4T). Only the server spans are counted as
+	// requests or, for the traces without any server span, the root spans. The Apdex metric is not emitted when zero
+	// and it must not be negative.
+	ApdexThreshold time.Duration `mapstructure:"apdex_threshold"`
 }
@@ -35,10 +35,12 @@ import (
 func TestLoadConfig(t *testing.T) {
 	defaultMethod := "GET"
 	testcases := []struct {
-		configFile                  string
-		wantMetricsExporter         string
-		wantLatencyHistogramBuckets []time.Duration
-		wantDimensions              []Dimension
+		configFile                         string
+		wantMetricsExporter                string
+		wantLatencyHistogramBuckets        []time.Duration
+		wantServiceLatencyHistogramBuckets map[string][]time.Duration
+		wantDimensions                     []Dimension
+		wantApdexThreshold                 time.Duration
 	}{
 		{configFile: "config-2-pipelines.yaml", wantMetricsExporter: "prometheus"},
 		{configFile: "config-3-pipelines.yaml", wantMetricsExporter: "otlp/spanmetrics"},
@@ -52,10 +54,14 @@ func TestLoadConfig(t *testing.T) {
 				100 * time.Millisecond,
 				250 * time.Millisecond,
 			},
+			wantServiceLatencyHistogramBuckets: map[string][]time.Duration{
+				"batchservice": {time.Second, 10 * time.Second, time.Minute},
+			},
 			wantDimensions: []Dimension{
 				{"http.method", &defaultMethod},
 				{"http.status_code", nil},
 			},
+			wantApdexThreshold: 100 * time.Millisecond,
 		},
 	}
 	for _, tc := range testcases {
@@ -86,9 +92,11 @@ func TestLoadConfig(t *testing.T) {
 						NameVal: "spanmetrics",
 						TypeVal: "spanmetrics",
 					},
-					MetricsExporter:         tc.wantMetricsExporter,
-					LatencyHistogramBuckets: tc.wantLatencyHistogramBuckets,
-					Dimensions:              tc.wantDimensions,
+					MetricsExporter:                tc.wantMetricsExporter,
+					LatencyHistogramBuckets:        tc.wantLatencyHistogramBuckets,
+					ServiceLatencyHistogramBuckets: tc.wantServiceLatencyHistogramBuckets,
+					Dimensions:                     tc.wantDimensions,
+					ApdexThreshold:                 tc.wantApdexThreshold,
 				},
 				cfg.Processors["spanmetrics"],
 			)
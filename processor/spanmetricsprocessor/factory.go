@@ -47,5 +47,9 @@ func createDefaultConfig() configmodels.Processor {
 }
 
 func createTraceProcessor(_ context.Context, params component.ProcessorCreateParams, cfg configmodels.Processor, nextConsumer consumer.TracesConsumer) (component.TracesProcessor, error) {
-	return newProcessor(params.Logger, cfg, nextConsumer), nil
+	p, err := newProcessor(params.Logger, cfg, nextConsumer)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
 }
@@ -16,13 +16,20 @@ package spanmetricsprocessor
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config/configmodels"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/translator/conventions"
+	tracetranslator "go.opentelemetry.io/collector/translator/trace"
 	"go.uber.org/zap"
 )
 
@@ -35,6 +42,34 @@ var (
 	}
 )
 
+const (
+	apdexMetricName   = "apdex"
+	latencyMetricName = "latency"
+
+	serviceNameKey  = "service.name"
+	operationKey    = "operation"
+	spanKindKey     = "span.kind"
+	statusCodeKey   = "status.code"
+	satisfactionKey = "satisfaction"
+
+	metricKeySeparator = string(byte(0))
+
+	apdexSatisfied  = "satisfied"
+	apdexTolerating = "tolerating"
+	apdexFrustrated = "frustrated"
+)
+
+// apdexKey identifies the requests counted together for the Apdex metric.
+type apdexKey struct {
+	serviceName string
+	operation   string
+}
+
+// apdexClassCounts holds the number of requests in each of the Apdex satisfaction classes.
+type apdexClassCounts struct {
+	satisfied, tolerating, frustrated int64
+}
+
 type processorImp struct {
 	logger *zap.Logger
 	config Config
@@ -53,35 +88,82 @@ type processorImp struct {
 	latencySum          map[string]float64
 	latencyBucketCounts map[string][]uint64
 	latencyBounds       []float64
+	// serviceLatencyBounds override latencyBounds for the services, keyed by their name.
+	serviceLatencyBounds map[string][]float64
+	// metricKeyLabels holds the labels of the metrics identified by each key.
+	metricKeyLabels map[string]map[string]string
+
+	// Apdex classification, enabled when the threshold is positive.
+	apdexThresholdMs float64
+	apdexCounts      map[apdexKey]*apdexClassCounts
+
+	// lock protects the aggregated metrics from concurrent updates.
+	lock sync.Mutex
 }
 
-func newProcessor(logger *zap.Logger, config configmodels.Exporter, nextConsumer consumer.TracesConsumer) *processorImp {
+func newProcessor(logger *zap.Logger, config configmodels.Exporter, nextConsumer consumer.TracesConsumer) (*processorImp, error) {
 	logger.Info("building spanmetricsprocessor")
 	pConfig := config.(*Config)
 
 	bounds := defaultLatencyHistogramBucketsMs
 	if pConfig.LatencyHistogramBuckets != nil {
-		bounds = mapDurationsToMillis(pConfig.LatencyHistogramBuckets, func(duration time.Duration) float64 {
-			return float64(duration.Milliseconds())
-		})
+		bounds = latencyBoundsMs(pConfig.LatencyHistogramBuckets)
+	}
 
-		// "Catch-all" bucket.
-		if bounds[len(bounds)-1] != maxDurationMs {
-			bounds = append(bounds, maxDurationMs)
+	serviceBounds := make(map[string][]float64, len(pConfig.ServiceLatencyHistogramBuckets))
+	for serviceName, buckets := range pConfig.ServiceLatencyHistogramBuckets {
+		if len(buckets) == 0 {
+			return nil, fmt.Errorf("latency histogram buckets of service %q must not be empty", serviceName)
 		}
+		for i := 1; i < len(buckets); i++ {
+			if buckets[i] <= buckets[i-1] {
+				return nil, fmt.Errorf("latency histogram buckets of service %q must be increasing", serviceName)
+			}
+		}
+		serviceBounds[serviceName] = latencyBoundsMs(buckets)
+	}
+
+	if pConfig.ApdexThreshold < 0 {
+		return nil, errors.New("apdex threshold must not be negative")
 	}
 
 	return &processorImp{
-		logger:              logger,
-		config:              *pConfig,
-		callSum:             make(map[string]int64),
-		latencyBounds:       bounds,
-		latencySum:          make(map[string]float64),
-		latencyCount:        make(map[string]uint64),
-		latencyBucketCounts: make(map[string][]uint64),
-		nextConsumer:        nextConsumer,
-		dimensions:          pConfig.Dimensions,
+		logger:               logger,
+		config:               *pConfig,
+		callSum:              make(map[string]int64),
+		latencyBounds:        bounds,
+		serviceLatencyBounds: serviceBounds,
+		latencySum:           make(map[string]float64),
+		latencyCount:         make(map[string]uint64),
+		latencyBucketCounts:  make(map[string][]uint64),
+		metricKeyLabels:      make(map[string]map[string]string),
+		nextConsumer:         nextConsumer,
+		dimensions:           pConfig.Dimensions,
+		apdexThresholdMs:     float64(pConfig.ApdexThreshold) / float64(time.Millisecond),
+		apdexCounts:          make(map[apdexKey]*apdexClassCounts),
+	}, nil
+}
+
+// latencyBoundsMs converts the latency histogram buckets to the bounds in milliseconds,
+// including the "catch-all" one.
+func latencyBoundsMs(buckets []time.Duration) []float64 {
+	bounds := mapDurationsToMillis(buckets, func(duration time.Duration) float64 {
+		return float64(duration.Milliseconds())
+	})
+
+	// "Catch-all" bucket.
+	if bounds[len(bounds)-1] != maxDurationMs {
+		bounds = append(bounds, maxDurationMs)
 	}
+	return bounds
+}
+
+// latencyBoundsFor returns the latency histogram bounds of the service, falling back to the global ones.
+func (p *processorImp) latencyBoundsFor(serviceName string) []float64 {
+	if bounds, ok := p.serviceLatencyBounds[serviceName]; ok {
+		return bounds
+	}
+	return p.latencyBounds
 }
 
 func mapDurationsToMillis(vs []time.Duration, f func(duration time.Duration) float64) []float64 {
@@ -121,9 +203,10 @@ func (p *processorImp) GetCapabilities() component.ProcessorCapabilities {
 func (p *processorImp) ConsumeTraces(ctx context.Context, traces pdata.Traces) error {
 	p.logger.Info("consuming trace data")
 
+	p.lock.Lock()
 	p.aggregateMetrics(traces)
-
 	m := p.buildMetrics()
+	p.lock.Unlock()
 
 	// Firstly, export metrics to avoid being impacted by downstream trace processor errors/latency.
 	if err := p.metricsExporter.ConsumeMetrics(ctx, *m); err != nil {
@@ -143,13 +226,219 @@ func (p *processorImp) ConsumeTraces(ctx context.Context, traces pdata.Traces) e
 func (p *processorImp) buildMetrics() *pdata.Metrics {
 	// TODO: Add implementation
 	m := pdata.NewMetrics()
+	if len(p.apdexCounts) == 0 && len(p.latencyCount) == 0 {
+		return &m
+	}
+
+	m.ResourceMetrics().Resize(1)
+	ilm := m.ResourceMetrics().At(0).InstrumentationLibraryMetrics()
+	ilm.Resize(1)
+	ilm.At(0).InstrumentationLibrary().SetName("spanmetricsprocessor")
+
+	p.buildApdexMetrics(ilm.At(0).Metrics())
+	p.buildLatencyMetrics(ilm.At(0).Metrics())
 	return &m
 }
 
+// buildLatencyMetrics writes the latency histograms, using the bucket layout of the service of each of them,
+// into the metrics object.
+func (p *processorImp) buildLatencyMetrics(metrics pdata.MetricSlice) {
+	if len(p.latencyCount) == 0 {
+		return
+	}
+
+	metrics.Resize(metrics.Len() + 1)
+	metric := metrics.At(metrics.Len() - 1)
+	metric.SetName(latencyMetricName)
+	metric.SetDescription("Latency of requests in milliseconds")
+	metric.SetUnit("ms")
+	metric.SetDataType(pdata.MetricDataTypeDoubleHistogram)
+	metric.DoubleHistogram().SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+
+	timestamp := pdata.TimestampUnixNano(time.Now().UnixNano())
+	dps := metric.DoubleHistogram().DataPoints()
+	for key, count := range p.latencyCount {
+		labels := p.metricKeyLabels[key]
+		dps.Resize(dps.Len() + 1)
+		dp := dps.At(dps.Len() - 1)
+		dp.SetTimestamp(timestamp)
+		dp.SetCount(count)
+		dp.SetSum(p.latencySum[key])
+		dp.SetBucketCounts(p.latencyBucketCounts[key])
+		dp.SetExplicitBounds(p.latencyBoundsFor(labels[serviceNameKey]))
+		dp.LabelsMap().InitFromMap(labels)
+	}
+}
+
+// buildApdexMetrics writes the Apdex request counts, labeled with their satisfaction class,
+// into the metrics object.
+func (p *processorImp) buildApdexMetrics(metrics pdata.MetricSlice) {
+	if len(p.apdexCounts) == 0 {
+		return
+	}
+
+	metrics.Resize(metrics.Len() + 1)
+	metric := metrics.At(metrics.Len() - 1)
+	metric.SetName(apdexMetricName)
+	metric.SetDescription("Count of requests by the Apdex satisfaction class")
+	metric.SetDataType(pdata.MetricDataTypeIntSum)
+	metric.IntSum().SetIsMonotonic(true)
+	metric.IntSum().SetAggregationTemporality(pdata.AggregationTemporalityCumulative)
+
+	timestamp := pdata.TimestampUnixNano(time.Now().UnixNano())
+	dps := metric.IntSum().DataPoints()
+	for key, counts := range p.apdexCounts {
+		for _, class := range []struct {
+			satisfaction string
+			count        int64
+		}{
+			{apdexSatisfied, counts.satisfied},
+			{apdexTolerating, counts.tolerating},
+			{apdexFrustrated, counts.frustrated},
+		} {
+			dps.Resize(dps.Len() + 1)
+			dp := dps.At(dps.Len() - 1)
+			dp.SetTimestamp(timestamp)
+			dp.SetValue(class.count)
+			dp.LabelsMap().InitFromMap(map[string]string{
+				serviceNameKey:  key.serviceName,
+				operationKey:    key.operation,
+				satisfactionKey: class.satisfaction,
+			})
+		}
+	}
+}
+
 // aggregateMetrics aggregates the raw metrics from the input trace data.
 // Each metric is identified by a key that is built from the service name
 // and span metadata such as operation, kind, status_code and any additional
 // dimensions the user has configured.
 func (p *processorImp) aggregateMetrics(traces pdata.Traces) {
 	// TODO: Add implementation
+	var tracesWithServerSpans map[[16]byte]struct{}
+	if p.apdexThresholdMs > 0 {
+		tracesWithServerSpans = findTracesWithServerSpans(traces)
+	}
+
+	rss := traces.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		serviceName := ""
+		if serviceAttr, ok := rs.Resource().Attributes().Get(conventions.AttributeServiceName); ok {
+			serviceName = serviceAttr.StringVal()
+		}
+
+		ilss := rs.InstrumentationLibrarySpans()
+		for j := 0; j < ilss.Len(); j++ {
+			spans := ilss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				latencyMs := float64(span.EndTime()-span.StartTime()) / float64(time.Millisecond)
+				p.updateLatency(serviceName, span, latencyMs)
+				if p.apdexThresholdMs > 0 && isApdexRequest(span, tracesWithServerSpans) {
+					p.updateApdex(apdexKey{serviceName: serviceName, operation: span.Name()}, latencyMs)
+				}
+			}
+		}
+	}
+}
+
+// updateLatency counts the request in the latency histogram of its metric key, using the bucket layout of its service.
+func (p *processorImp) updateLatency(serviceName string, span pdata.Span, latencyMs float64) {
+	key := p.buildKey(serviceName, span)
+	bounds := p.latencyBoundsFor(serviceName)
+	if _, ok := p.latencyBucketCounts[key]; !ok {
+		p.latencyBucketCounts[key] = make([]uint64, len(bounds))
+	}
+
+	index := sort.SearchFloat64s(bounds, latencyMs)
+	if index == len(bounds) {
+		// Latencies beyond the "catch-all" bound are rounding artifacts only.
+		index--
+	}
+	p.latencyBucketCounts[key][index]++
+	p.latencyCount[key]++
+	p.latencySum[key] += latencyMs
+}
+
+// buildKey returns the key identifying the metrics of the span, built from the service name, operation, span kind,
+// status code and the additional dimensions. The labels of the metrics are stored along with the key.
+func (p *processorImp) buildKey(serviceName string, span pdata.Span) string {
+	labels := map[string]string{
+		serviceNameKey: serviceName,
+		operationKey:   span.Name(),
+		spanKindKey:    span.Kind().String(),
+		statusCodeKey:  span.Status().Code().String(),
+	}
+	keyParts := []string{serviceName, span.Name(), labels[spanKindKey], labels[statusCodeKey]}
+
+	attrs := span.Attributes()
+	for _, d := range p.dimensions {
+		value, ok := "", false
+		if attr, found := attrs.Get(d.Name); found {
+			value, ok = tracetranslator.AttributeValueToString(attr, false), true
+		} else if d.Default != nil {
+			value, ok = *d.Default, true
+		}
+		if ok {
+			// The dimension is omitted when the attribute is missing and it has no default.
+			labels[d.Name] = value
+			keyParts = append(keyParts, d.Name+"="+value)
+		}
+	}
+
+	key := strings.Join(keyParts, metricKeySeparator)
+	if _, ok := p.metricKeyLabels[key]; !ok {
+		p.metricKeyLabels[key] = labels
+	}
+	return key
+}
+
+// findTracesWithServerSpans returns the IDs of the traces having any server span in the batch.
+func findTracesWithServerSpans(traces pdata.Traces) map[[16]byte]struct{} {
+	traceIDs := make(map[[16]byte]struct{})
+	rss := traces.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		ilss := rss.At(i).InstrumentationLibrarySpans()
+		for j := 0; j < ilss.Len(); j++ {
+			spans := ilss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				if span := spans.At(k); span.Kind() == pdata.SpanKindSERVER {
+					traceIDs[span.TraceID().Bytes()] = struct{}{}
+				}
+			}
+		}
+	}
+	return traceIDs
+}
+
+// isApdexRequest returns true if the span is a request counted for the Apdex metric, i.e. a server span or,
+// when its trace has no server span in the batch, the root span.
+func isApdexRequest(span pdata.Span, tracesWithServerSpans map[[16]byte]struct{}) bool {
+	if span.Kind() == pdata.SpanKindSERVER {
+		return true
+	}
+	if !span.ParentSpanID().IsEmpty() {
+		return false
+	}
+	_, found := tracesWithServerSpans[span.TraceID().Bytes()]
+	return !found
+}
+
+// updateApdex classifies the request by its latency and counts it in the matching Apdex satisfaction class.
+func (p *processorImp) updateApdex(key apdexKey, latencyMs float64) {
+	counts, ok := p.apdexCounts[key]
+	if !ok {
+		counts = &apdexClassCounts{}
+		p.apdexCounts[key] = counts
+	}
+
+	switch {
+	case latencyMs <= p.apdexThresholdMs:
+		counts.satisfied++
+	case latencyMs <= 4*p.apdexThresholdMs:
+		counts.tolerating++
+	default:
+		counts.frustrated++
+	}
 }
@@ -18,12 +18,14 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/translator/conventions"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/metadata"
 
@@ -47,7 +49,8 @@ func TestProcessorShutdown(t *testing.T) {
 
 	// Test
 	next := new(consumertest.TracesSink)
-	p := newProcessor(zap.NewNop(), cfg, next)
+	p, err := newProcessor(zap.NewNop(), cfg, next)
+	require.NoError(t, err)
 	require.NotNil(t, p)
 	// Verify
 	assert.NoError(t, p.Shutdown(context.Background()))
@@ -60,7 +63,8 @@ func TestProcessorCapabilities(t *testing.T) {
 
 	// Test
 	next := new(consumertest.TracesSink)
-	p := newProcessor(zap.NewNop(), cfg, next)
+	p, err := newProcessor(zap.NewNop(), cfg, next)
+	require.NoError(t, err)
 	require.NotNil(t, p)
 	caps := p.GetCapabilities()
 
@@ -119,3 +123,215 @@ func TestProcessorConsumeTraces(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessorApdex(t *testing.T) {
+	// Prepare
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.ApdexThreshold = 100 * time.Millisecond
+
+	mexp := &mocks.MetricsExporter{}
+	mexp.On("ConsumeMetrics", mock.Anything, mock.Anything).Return(nil)
+	p, err := newProcessor(zap.NewNop(), cfg, new(consumertest.TracesSink))
+	require.NoError(t, err)
+	p.metricsExporter = mexp
+
+	traces := buildTracesWithLatencies("service-a", "/checkout", []time.Duration{
+		50 * time.Millisecond,  // satisfied
+		100 * time.Millisecond, // satisfied, at threshold
+		250 * time.Millisecond, // tolerating
+		400 * time.Millisecond, // tolerating, at 4 times the threshold
+		401 * time.Millisecond, // frustrated
+	})
+
+	// Test
+	require.NoError(t, p.ConsumeTraces(context.Background(), traces))
+
+	// Verify
+	key := apdexKey{serviceName: "service-a", operation: "/checkout"}
+	require.Contains(t, p.apdexCounts, key)
+	assert.Equal(t, apdexClassCounts{satisfied: 2, tolerating: 2, frustrated: 1}, *p.apdexCounts[key])
+
+	m := p.buildMetrics()
+	require.Equal(t, 1, m.ResourceMetrics().Len())
+	metric := m.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(0)
+	assert.Equal(t, apdexMetricName, metric.Name())
+
+	counts := make(map[string]int64)
+	dps := metric.IntSum().DataPoints()
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		serviceName, _ := dp.LabelsMap().Get(serviceNameKey)
+		assert.Equal(t, "service-a", serviceName)
+		satisfaction, _ := dp.LabelsMap().Get(satisfactionKey)
+		counts[satisfaction] = dp.Value()
+	}
+	assert.Equal(t, map[string]int64{apdexSatisfied: 2, apdexTolerating: 2, apdexFrustrated: 1}, counts)
+}
+
+func TestProcessorApdexDisabled(t *testing.T) {
+	// Prepare
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	p, err := newProcessor(zap.NewNop(), cfg, new(consumertest.TracesSink))
+	require.NoError(t, err)
+
+	// Test
+	p.aggregateMetrics(buildTracesWithLatencies("service-a", "/checkout", []time.Duration{time.Second}))
+
+	// Verify
+	assert.Empty(t, p.apdexCounts)
+	m := p.buildMetrics()
+	require.Equal(t, 1, m.ResourceMetrics().Len())
+	metrics := m.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		assert.NotEqual(t, apdexMetricName, metrics.At(i).Name())
+	}
+}
+
+func TestProcessorApdexCountsRequests(t *testing.T) {
+	// Prepare
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.ApdexThreshold = 100 * time.Millisecond
+	p, err := newProcessor(zap.NewNop(), cfg, new(consumertest.TracesSink))
+	require.NoError(t, err)
+
+	traces := pdata.NewTraces()
+	traces.ResourceSpans().Resize(1)
+	rs := traces.ResourceSpans().At(0)
+	rs.Resource().Attributes().InsertString(conventions.AttributeServiceName, "service-a")
+	rs.InstrumentationLibrarySpans().Resize(1)
+	spans := rs.InstrumentationLibrarySpans().At(0).Spans()
+	spans.Resize(5)
+
+	// The first trace has a client root span calling the server span, which has an internal child span.
+	// The second trace has no server span, so its root span is the request.
+	withServerSpan := pdata.NewTraceID([16]byte{1})
+	withoutServerSpan := pdata.NewTraceID([16]byte{2})
+	for i, tc := range []struct {
+		traceID  pdata.TraceID
+		name     string
+		kind     pdata.SpanKind
+		parentID pdata.SpanID
+	}{
+		{traceID: withServerSpan, name: "client", kind: pdata.SpanKindCLIENT},
+		{traceID: withServerSpan, name: "server", kind: pdata.SpanKindSERVER, parentID: pdata.NewSpanID([8]byte{1})},
+		{traceID: withServerSpan, name: "internal", kind: pdata.SpanKindINTERNAL, parentID: pdata.NewSpanID([8]byte{2})},
+		{traceID: withoutServerSpan, name: "job", kind: pdata.SpanKindINTERNAL},
+		{traceID: withoutServerSpan, name: "query", kind: pdata.SpanKindCLIENT, parentID: pdata.NewSpanID([8]byte{3})},
+	} {
+		span := spans.At(i)
+		span.SetTraceID(tc.traceID)
+		span.SetName(tc.name)
+		span.SetKind(tc.kind)
+		span.SetParentSpanID(tc.parentID)
+	}
+
+	// Test
+	p.aggregateMetrics(traces)
+
+	// Verify
+	assert.Len(t, p.apdexCounts, 2)
+	assert.Contains(t, p.apdexCounts, apdexKey{serviceName: "service-a", operation: "server"})
+	assert.Contains(t, p.apdexCounts, apdexKey{serviceName: "service-a", operation: "job"})
+}
+
+func TestProcessorNegativeApdexThreshold(t *testing.T) {
+	// Prepare
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.ApdexThreshold = -time.Second
+
+	// Test
+	_, err := newProcessor(zap.NewNop(), cfg, new(consumertest.TracesSink))
+
+	// Verify
+	assert.Error(t, err)
+}
+
+func TestProcessorServiceLatencyHistogramBuckets(t *testing.T) {
+	// Prepare
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.LatencyHistogramBuckets = []time.Duration{10 * time.Millisecond, 100 * time.Millisecond}
+	cfg.ServiceLatencyHistogramBuckets = map[string][]time.Duration{
+		"batch-service": {time.Second, 10 * time.Second, time.Minute},
+	}
+	p, err := newProcessor(zap.NewNop(), cfg, new(consumertest.TracesSink))
+	require.NoError(t, err)
+
+	latencies := []time.Duration{5 * time.Millisecond, 50 * time.Millisecond, 2 * time.Second, 30 * time.Second}
+
+	// Test
+	p.aggregateMetrics(buildTracesWithLatencies("web-service", "/checkout", latencies))
+	p.aggregateMetrics(buildTracesWithLatencies("batch-service", "import", latencies))
+
+	// Verify
+	m := p.buildMetrics()
+	require.Equal(t, 1, m.ResourceMetrics().Len())
+	metric := m.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(0)
+	assert.Equal(t, latencyMetricName, metric.Name())
+
+	dps := metric.DoubleHistogram().DataPoints()
+	require.Equal(t, 2, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		assert.EqualValues(t, len(latencies), dp.Count())
+		serviceName, _ := dp.LabelsMap().Get(serviceNameKey)
+		switch serviceName {
+		case "web-service":
+			// The global buckets are used, so the two slowest requests fall into the "catch-all" bucket.
+			assert.Equal(t, []float64{10, 100, maxDurationMs}, dp.ExplicitBounds())
+			assert.Equal(t, []uint64{1, 1, 2}, dp.BucketCounts())
+		case "batch-service":
+			assert.Equal(t, []float64{1000, 10_000, 60_000, maxDurationMs}, dp.ExplicitBounds())
+			assert.Equal(t, []uint64{2, 1, 1, 0}, dp.BucketCounts())
+		default:
+			assert.Fail(t, "unexpected service", serviceName)
+		}
+	}
+}
+
+func TestProcessorInvalidServiceLatencyHistogramBuckets(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		buckets []time.Duration
+	}{
+		{name: "empty", buckets: []time.Duration{}},
+		{name: "decreasing", buckets: []time.Duration{time.Second, 100 * time.Millisecond}},
+		{name: "repeated", buckets: []time.Duration{time.Second, time.Second}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Prepare
+			factory := NewFactory()
+			cfg := factory.CreateDefaultConfig().(*Config)
+			cfg.ServiceLatencyHistogramBuckets = map[string][]time.Duration{"batch-service": tc.buckets}
+
+			// Test
+			_, err := newProcessor(zap.NewNop(), cfg, new(consumertest.TracesSink))
+
+			// Verify
+			assert.Error(t, err)
+		})
+	}
+}
+
+func buildTracesWithLatencies(serviceName string, operation string, latencies []time.Duration) pdata.Traces {
+	traces := pdata.NewTraces()
+	traces.ResourceSpans().Resize(1)
+	rs := traces.ResourceSpans().At(0)
+	rs.Resource().Attributes().InsertString(conventions.AttributeServiceName, serviceName)
+	rs.InstrumentationLibrarySpans().Resize(1)
+	spans := rs.InstrumentationLibrarySpans().At(0).Spans()
+	spans.Resize(len(latencies))
+
+	start := time.Now()
+	for i, latency := range latencies {
+		span := spans.At(i)
+		span.SetName(operation)
+		span.SetStartTime(pdata.TimestampUnixNano(start.UnixNano()))
+		span.SetEndTime(pdata.TimestampUnixNano(start.Add(latency).UnixNano()))
+	}
+	return traces
+}